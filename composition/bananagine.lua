@@ -60,8 +60,8 @@ pulp.on("bananagine.template-catalog.v1.replace", function(request)
   return template_catalog_call("bananagine.template-catalog.v1.replace", request)
 end)
 
-pulp.on("bananagine.template-catalog.v1.list", function(request)
-  return template_catalog_call("bananagine.template-catalog.v1.list", request)
+pulp.on("bananagine.template-catalog.v1.list", function(filter)
+  return template_catalog_call("bananagine.template-catalog.v1.list", filter or {})
 end)
 
 pulp.on("bananagine.template-catalog.v1.get", function(request)
@@ -76,6 +76,30 @@ pulp.on("bananagine.template-catalog.v1.snapshot.import", function(request)
   return template_catalog_call("bananagine.template-catalog.v1.snapshot.import", request)
 end)
 
+pulp.on("bananagine.template-catalog.v1.validate", function(request)
+  return template_catalog_call("bananagine.template-catalog.v1.validate", request)
+end)
+
+pulp.on("bananagine.template-catalog.v1.history", function(request)
+  return template_catalog_call("bananagine.template-catalog.v1.history", request)
+end)
+
+pulp.on("bananagine.template-catalog.v1.rollback", function(request)
+  return template_catalog_call("bananagine.template-catalog.v1.rollback", request)
+end)
+
+pulp.on("bananagine.template-catalog.v1.diff", function(request)
+  return template_catalog_call("bananagine.template-catalog.v1.diff", request)
+end)
+
+pulp.on("bananagine.template-catalog.v1.upsert", function(request)
+  return template_catalog_call("bananagine.template-catalog.v1.upsert", request)
+end)
+
+pulp.on("bananagine.template-catalog.v1.delete", function(request)
+  return template_catalog_call("bananagine.template-catalog.v1.delete", request)
+end)
+
 pulp.on("bananagine.worker.v1.http.submit", function(request)
   return worker_call("bananagine.worker.v1.http.submit", request)
 end)
@@ -4,10 +4,14 @@
 package orchestration
 
 const (
-	HealthPath    = "/health"
-	TemplatesPath = "/templates"
-	ServersPath   = "/orchestration/servers"
-	StatsPath     = "/orchestration/stats"
+	HealthPath       = "/health"
+	TemplatesPath    = "/templates"
+	ServersPath      = "/orchestration/servers"
+	StatsPath        = "/orchestration/stats"
+	WarmPoolPath     = "/orchestration/warm-pool"
+	QuotasPath       = "/orchestration/quotas"
+	ReservationsPath = "/orchestration/reservations"
+	AdmissionPath    = "/orchestration/admission"
 )
 
 // ResourceOverride applies caller-selected limits over a template's defaults.
@@ -20,11 +24,125 @@ type ResourceOverride struct {
 }
 
 // CreateServerRequest asks Bananagine to instantiate a named template.
+// TTLSeconds or ExpiresAt (unix seconds), when set, lease the server: it is
+// destroyed once the lease lapses unless renewed. Owner charges the server to a
+// tenant's quota and is recorded as a container label. ReservationID consumes a
+// capacity reservation made through ReservationsPath. Tier names the resource
+// tier Resources was sized from; it is recorded as a label for filtering.
 type CreateServerRequest struct {
-	Template  string            `json:"template" msgpack:"template"`
-	ServerID  string            `json:"server_id,omitempty" msgpack:"server_id,omitempty"`
-	Env       map[string]string `json:"env,omitempty" msgpack:"env,omitempty"`
-	Resources *ResourceOverride `json:"resources,omitempty" msgpack:"resources,omitempty"`
+	Template   string            `json:"template" msgpack:"template"`
+	ServerID   string            `json:"server_id,omitempty" msgpack:"server_id,omitempty"`
+	Env        map[string]string `json:"env,omitempty" msgpack:"env,omitempty"`
+	Resources  *ResourceOverride `json:"resources,omitempty" msgpack:"resources,omitempty"`
+	TTLSeconds int64             `json:"ttl_seconds,omitempty" msgpack:"ttl_seconds,omitempty"`
+	ExpiresAt  int64             `json:"expires_at,omitempty" msgpack:"expires_at,omitempty"`
+	// IdleSuspendMinutes overrides the template's idle policy: positive sets
+	// the threshold, negative opts this server out, zero inherits.
+	IdleSuspendMinutes int    `json:"idle_suspend_minutes,omitempty" msgpack:"idle_suspend_minutes,omitempty"`
+	Owner              string `json:"owner,omitempty" msgpack:"owner,omitempty"`
+	ReservationID      string `json:"reservation_id,omitempty" msgpack:"reservation_id,omitempty"`
+	Tier               string `json:"tier,omitempty" msgpack:"tier,omitempty"`
+	// Ports requests specific host ports by template port name. Each must lie
+	// in that port's range and be free; host-mode templates only.
+	Ports map[string]int `json:"ports,omitempty" msgpack:"ports,omitempty"`
+}
+
+// ReservationRequest holds CPU, memory and optionally one port per listed
+// range (empty string for the node's default pool) for TTLSeconds. Memory is in
+// bytes like ResourceOverride.MemoryLimit.
+type ReservationRequest struct {
+	CPULimit    float64  `json:"cpu_limit,omitempty" msgpack:"cpu_limit,omitempty"`
+	MemoryLimit int64    `json:"memory_limit,omitempty" msgpack:"memory_limit,omitempty"`
+	PortRanges  []string `json:"port_ranges,omitempty" msgpack:"port_ranges,omitempty"`
+	TTLSeconds  int64    `json:"ttl_seconds,omitempty" msgpack:"ttl_seconds,omitempty"`
+}
+
+// Reservation is held capacity that lapses at ExpiresAt (unix seconds) unless a
+// create consumes it first.
+type Reservation struct {
+	ID          string   `json:"id" msgpack:"id"`
+	CPULimit    float64  `json:"cpu_limit" msgpack:"cpu_limit"`
+	MemoryLimit int64    `json:"memory_limit" msgpack:"memory_limit"`
+	PortRanges  []string `json:"port_ranges,omitempty" msgpack:"port_ranges,omitempty"`
+	Ports       []int    `json:"ports,omitempty" msgpack:"ports,omitempty"`
+	ExpiresAt   int64    `json:"expires_at" msgpack:"expires_at"`
+}
+
+// RenewLeaseRequest moves a leased server's expiry. Exactly one of TTLSeconds
+// (relative to now) or ExpiresAt (unix seconds) must be set.
+type RenewLeaseRequest struct {
+	TTLSeconds int64 `json:"ttl_seconds,omitempty" msgpack:"ttl_seconds,omitempty"`
+	ExpiresAt  int64 `json:"expires_at,omitempty" msgpack:"expires_at,omitempty"`
+}
+
+// ServerLease is the current expiry of one leased server.
+type ServerLease struct {
+	ID        string `json:"id" msgpack:"id"`
+	ServerID  string `json:"server_id" msgpack:"server_id"`
+	ExpiresAt int64  `json:"expires_at" msgpack:"expires_at"`
+}
+
+// ClaimServerRequest binds an idle warm-pool standby of Template to ServerID.
+// Env is applied through the runtime reconfigure path, so it accepts the same
+// FLEET_SETTING_* and FLEET_GAMERULE_* keys as the fleet reconfigure action.
+type ClaimServerRequest struct {
+	Template string            `json:"template" msgpack:"template"`
+	ServerID string            `json:"server_id" msgpack:"server_id"`
+	Env      map[string]string `json:"env,omitempty" msgpack:"env,omitempty"`
+	Owner    string            `json:"owner,omitempty" msgpack:"owner,omitempty"`
+}
+
+// WarmPoolStatus reports one template's configured and idle standby counts.
+type WarmPoolStatus struct {
+	Template string `json:"template" msgpack:"template"`
+	Size     int    `json:"size" msgpack:"size"`
+	Idle     int    `json:"idle" msgpack:"idle"`
+}
+
+// AdmissionResponse answers whether a CreateServerRequest would be admitted on
+// this node right now. Reasons lists every failing dimension.
+type AdmissionResponse struct {
+	Fits       bool                 `json:"fits" msgpack:"fits"`
+	Reasons    []string             `json:"reasons,omitempty" msgpack:"reasons,omitempty"`
+	Dimensions []AdmissionDimension `json:"dimensions" msgpack:"dimensions"`
+}
+
+// AdmissionDimension is one resource the create would draw on: "cpu",
+// "memory_gib", "ip", "ports" (Range names the pool, empty for the default
+// pool), "quota" or "reservation". Available is the headroom before the
+// create; Unlimited dimensions have no budget.
+type AdmissionDimension struct {
+	Name      string  `json:"name" msgpack:"name"`
+	Range     string  `json:"range,omitempty" msgpack:"range,omitempty"`
+	Requested float64 `json:"requested" msgpack:"requested"`
+	Available float64 `json:"available" msgpack:"available"`
+	Unlimited bool    `json:"unlimited,omitempty" msgpack:"unlimited,omitempty"`
+	Fits      bool    `json:"fits" msgpack:"fits"`
+	Reason    string  `json:"reason,omitempty" msgpack:"reason,omitempty"`
+}
+
+// TenantQuota caps what one owner may run on a node. Zero leaves a dimension
+// unlimited.
+type TenantQuota struct {
+	MaxServers       int     `json:"max_servers,omitempty" msgpack:"max_servers,omitempty"`
+	MaxCPU           float64 `json:"max_cpu,omitempty" msgpack:"max_cpu,omitempty"`
+	MaxMemoryGiB     float64 `json:"max_memory_gib,omitempty" msgpack:"max_memory_gib,omitempty"`
+	MaxWorldsDiskGiB float64 `json:"max_worlds_disk_gib,omitempty" msgpack:"max_worlds_disk_gib,omitempty"`
+}
+
+// TenantUsage is what one owner currently runs on a node.
+type TenantUsage struct {
+	Servers       int     `json:"servers" msgpack:"servers"`
+	CPU           float64 `json:"cpu" msgpack:"cpu"`
+	MemoryGiB     float64 `json:"memory_gib" msgpack:"memory_gib"`
+	WorldsDiskGiB float64 `json:"worlds_disk_gib" msgpack:"worlds_disk_gib"`
+}
+
+// QuotaStatus pairs an owner's configured quota with its current usage.
+type QuotaStatus struct {
+	Owner string      `json:"owner" msgpack:"owner"`
+	Quota TenantQuota `json:"quota" msgpack:"quota"`
+	Usage TenantUsage `json:"usage" msgpack:"usage"`
 }
 
 // TemplateInfo is the public template catalog entry returned by Bananagine.
@@ -46,6 +164,8 @@ type Server struct {
 	Ports       map[string]int `json:"ports" msgpack:"ports"`
 	CPULimit    float64        `json:"cpu_limit,omitempty" msgpack:"cpu_limit,omitempty"`
 	MemoryLimit int64          `json:"memory_limit,omitempty" msgpack:"memory_limit,omitempty"`
+	// Labels carries the bananagine.* ownership labels stamped at create.
+	Labels map[string]string `json:"labels,omitempty" msgpack:"labels,omitempty"`
 }
 
 // ContainerStats is the runtime telemetry Bananagine reports per container.
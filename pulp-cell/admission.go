@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+// admission sizes req exactly like create (platform ports, then
+// resources.Apply) and checks every allocator create would draw on, without
+// calling hooks or touching any allocator. Settings, option targets and
+// template interpolation are checked too, because create rejects a request
+// that fails them with 400. A ReservationID counts the room it
+// holds as headroom, because create frees that hold before allocating.
+func (p *provisioner) admission(req createServerRequest, now time.Time) (orchestration.AdmissionResponse, *provisionError) {
+	tmpl, ok := p.templates[req.Template]
+	if !ok {
+		return orchestration.AdmissionResponse{}, &provisionError{status: 404, message: "template not found"}
+	}
+	var dims []orchestration.AdmissionDimension
+	if env, fields := validateCallerSettings(tmpl, req.Env); len(fields) > 0 {
+		messages := make([]string, len(fields))
+		for i, field := range fields {
+			messages[i] = field.Message
+		}
+		dims = append(dims, orchestration.AdmissionDimension{
+			Name: "settings", Reason: "invalid settings: " + strings.Join(messages, "; "),
+		})
+	} else {
+		req.Env = env
+	}
+
+	container := deepCopyContainer(tmpl.Container)
+	filterPlatformPorts(tmpl, &container, req.Env)
+	if len(dims) == 0 {
+		if err := admissionRender(tmpl, container, req, p.cfg.ExternalHost); err != nil {
+			dims = append(dims, orchestration.AdmissionDimension{Name: "template", Reason: err.Error()})
+		}
+	}
+	applyResourceOverride(&container, req)
+
+	var held orchestration.Reservation
+	if req.ReservationID != "" {
+		reservation, ok := p.reservations.peek(req.ReservationID, now)
+		dim := orchestration.AdmissionDimension{Name: "reservation", Requested: 1, Fits: ok}
+		if ok {
+			held = reservation
+			dim.Available = 1
+		} else {
+			dim.Reason = "reservation not found or expired"
+		}
+		dims = append(dims, dim)
+	}
+
+	ct := p.capacity
+	dims = append(dims,
+		admissionDimension("cpu", "", container.CPULimit,
+			ct.cpuBudget-ct.allocCPU+held.CPULimit, ct.cpuBudget <= 0),
+		admissionDimension("memory_gib", "", bytesToGiB(container.MemoryLimit),
+			ct.memBudget-ct.allocMem+bytesToGiB(held.MemoryLimit), ct.memBudget <= 0),
+	)
+
+	if err := validateRequestedPorts(container, req.Ports); err != nil {
+		dims = append(dims, orchestration.AdmissionDimension{Name: "ports", Reason: err.Error()})
+	} else if container.Network != "" {
+		dims = append(dims, admissionDimension("ip", container.Network, 1, float64(p.ipp.available(container.Network)), false))
+	} else {
+		var dynamic []PortSpec
+		for _, spec := range container.Ports {
+			requested, ok := req.Ports[spec.Name]
+			if !ok || spec.Name == "" {
+				dynamic = append(dynamic, spec)
+				continue
+			}
+			dim := orchestration.AdmissionDimension{Name: "port", Range: strconv.Itoa(requested), Requested: 1, Available: 1, Fits: true}
+			if err := p.portPools.claimable(spec.Range, requested, req.ServerID); err != nil {
+				dim.Available, dim.Fits, dim.Reason = 0, false, err.Error()
+			}
+			dims = append(dims, dim)
+		}
+		needs := portNeeds(dynamic)
+		if len(dynamic) == 0 && len(container.Ports) > 0 {
+			// Every port was requested, so nothing comes from a pool.
+			needs = nil
+		}
+		for _, need := range needs {
+			free, err := p.portPools.available(need.portRange)
+			if err != nil {
+				dims = append(dims, orchestration.AdmissionDimension{
+					Name: "ports", Range: need.portRange, Requested: float64(need.count), Reason: err.Error(),
+				})
+				continue
+			}
+			for _, heldRange := range held.PortRanges {
+				if strings.TrimSpace(heldRange) == need.portRange {
+					free++
+				}
+			}
+			dims = append(dims, admissionDimension("ports", need.portRange, float64(need.count), float64(free), false))
+		}
+	}
+
+	if req.Owner != "" {
+		// Quota spans several limits, so this dimension reports only the verdict.
+		_, limited := p.quotas.quotas[req.Owner]
+		dim := orchestration.AdmissionDimension{Name: "quota", Unlimited: !limited, Fits: true}
+		if err := p.quotas.admit(req.Owner, container.CPULimit, container.MemoryLimit); err != nil {
+			dim.Fits, dim.Reason = false, err.Error()
+		}
+		dims = append(dims, dim)
+	}
+
+	response := orchestration.AdmissionResponse{Fits: true, Dimensions: dims}
+	for _, dim := range dims {
+		if !dim.Fits {
+			response.Fits = false
+			response.Reasons = append(response.Reasons, dim.Reason)
+		}
+	}
+	return response, nil
+}
+
+// admissionRender runs create's setting translation, interpolation and file
+// rendering with placeholder allocations. Which references resolve does not
+// depend on the allocated values, so an error here is one create would
+// return with 400.
+func admissionRender(tmpl Template, container ContainerSpec, req createServerRequest, externalHost string) error {
+	settings, _, err := translateSettings(tmpl.Config, req.Env)
+	if err != nil {
+		return err
+	}
+	container = deepCopyContainer(container)
+	serverID := req.ServerID
+	if serverID == "" {
+		serverID = req.Template
+	}
+	env := make(map[string]string, len(container.Environment)+len(tmpl.Server)+3)
+	for k, v := range container.Environment {
+		env[k] = v
+	}
+	for k, v := range tmpl.Server {
+		env[k] = v
+	}
+	env["SERVER_ID"], env["SERVER_HOST"], env["SERVER_PORT"] = serverID, "0.0.0.0", "0"
+	for _, port := range container.Ports {
+		if port.Name != "" {
+			env["PORT_"+strings.ToUpper(port.Name)] = "0"
+		}
+	}
+	container.Environment = env
+
+	vars := interpolationVars(req.Template, container.Ports, container.Environment, req.Env, externalHost)
+	if err := interpolateContainer(&container, vars); err != nil {
+		return err
+	}
+	if _, err := interpolateURL(tmpl.Hooks.PreStart, vars); err != nil {
+		return fmt.Errorf("pre_start hook: %w", err)
+	}
+	if len(settings.properties) > 0 {
+		if _, ok := volumeHostPath(container.Volumes, settings.propertiesFile); !ok {
+			return fmt.Errorf("no volume holds %s", settings.propertiesFile)
+		}
+	}
+	_, err = renderFiles(tmpl.Files, container.Volumes, vars)
+	return err
+}
+
+type portNeed struct {
+	portRange string
+	count     int
+}
+
+// portNeeds groups a container's ports by pool in declaration order. A
+// container without ports still takes one port from the default pool.
+func portNeeds(ports []PortSpec) []portNeed {
+	if len(ports) == 0 {
+		return []portNeed{{count: 1}}
+	}
+	var needs []portNeed
+	index := make(map[string]int)
+	for _, port := range ports {
+		if i, ok := index[port.Range]; ok {
+			needs[i].count++
+			continue
+		}
+		index[port.Range] = len(needs)
+		needs = append(needs, portNeed{portRange: port.Range, count: 1})
+	}
+	return needs
+}
+
+func admissionDimension(name, portRange string, requested, available float64, unlimited bool) orchestration.AdmissionDimension {
+	dim := orchestration.AdmissionDimension{
+		Name:      name,
+		Range:     portRange,
+		Requested: requested,
+		Available: available,
+		Unlimited: unlimited,
+		Fits:      unlimited || requested <= available,
+	}
+	if unlimited {
+		dim.Available = 0
+	}
+	if !dim.Fits {
+		label := name
+		if portRange != "" {
+			label = fmt.Sprintf("%s %s", name, portRange)
+		}
+		dim.Reason = fmt.Sprintf("%s exhausted (requested %.2f, available %.2f)", label, requested, available)
+	}
+	return dim
+}
+
+func registerAdmissionRoutes(group *pulpgin.RouterGroup, prov *provisioner) {
+	group.POST("/admission", func(c *pulpgin.Context) {
+		var req createServerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		response, perr := prov.admission(req, time.Now())
+		if perr != nil {
+			c.JSON(perr.status, pulpgin.H{"error": perr.message})
+			return
+		}
+		c.JSON(200, response)
+	})
+}
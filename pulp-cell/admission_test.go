@@ -0,0 +1,165 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+func newTestAdmissionProvisioner() *provisioner {
+	capacity := newCapacityTracker(4, 8)
+	portPools := newPortPoolSet(newPortPool(6000, 6009))
+	return &provisioner{
+		templates: map[string]Template{
+			"mc": {Name: "mc", Container: ContainerSpec{
+				CPULimit:    2,
+				MemoryLimit: 4 * 1024 * 1024 * 1024,
+				Ports: []PortSpec{
+					{Name: "java", Range: "25565-25566"},
+					{Name: "bedrock", Range: "25565-25566"},
+				},
+			}},
+		},
+		bindings:     make(map[string]string),
+		capacity:     capacity,
+		ipp:          mustIPPoolSet("10.0.0.1", "10.0.0.4"),
+		portPools:    portPools,
+		quotas:       newQuotaTracker(map[string]orchestration.TenantQuota{"acme": {MaxServers: 1}}, "worlds"),
+		reservations: newReservationBook(capacity, portPools),
+	}
+}
+
+func admissionDim(t *testing.T, response orchestration.AdmissionResponse, name string) orchestration.AdmissionDimension {
+	t.Helper()
+	for _, dim := range response.Dimensions {
+		if dim.Name == name {
+			return dim
+		}
+	}
+	t.Fatalf("dimension %q missing from %+v", name, response.Dimensions)
+	return orchestration.AdmissionDimension{}
+}
+
+func TestAdmissionFitsAndReportsHeadroom(t *testing.T) {
+	p := newTestAdmissionProvisioner()
+	response, perr := p.admission(createServerRequest{Template: "mc", Owner: "acme"}, time.Unix(1000, 0))
+	if perr != nil {
+		t.Fatalf("admission: %s", perr.message)
+	}
+	if !response.Fits || len(response.Reasons) != 0 {
+		t.Fatalf("response = %+v", response)
+	}
+	if dim := admissionDim(t, response, "cpu"); dim.Requested != 2 || dim.Available != 4 {
+		t.Fatalf("cpu = %+v", dim)
+	}
+	if dim := admissionDim(t, response, "ports"); dim.Range != "25565-25566" || dim.Requested != 2 || dim.Available != 2 {
+		t.Fatalf("ports = %+v", dim)
+	}
+	if p.capacity.allocCPU != 0 || len(p.portPools.pools) != 0 {
+		t.Fatal("admission touched an allocator")
+	}
+}
+
+func TestAdmissionRejectionsListEveryReason(t *testing.T) {
+	p := newTestAdmissionProvisioner()
+	if err := p.capacity.tryAllocate("busy", 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	p.quotas.charge("busy", "acme", "mc-busy", 3, 0)
+	response, perr := p.admission(createServerRequest{
+		Template:  "mc",
+		Owner:     "acme",
+		Resources: &orchestration.ResourceOverride{MaxRamMb: 16 * 1024},
+	}, time.Unix(1000, 0))
+	if perr != nil {
+		t.Fatalf("admission: %s", perr.message)
+	}
+	if response.Fits || len(response.Reasons) != 3 {
+		t.Fatalf("response = %+v", response)
+	}
+	for _, name := range []string{"cpu", "memory_gib", "quota"} {
+		if admissionDim(t, response, name).Fits {
+			t.Fatalf("%s should not fit: %+v", name, response)
+		}
+	}
+}
+
+func TestAdmissionCountsReservationHeadroom(t *testing.T) {
+	p := newTestAdmissionProvisioner()
+	now := time.Unix(1000, 0)
+	reservation, perr := p.reservations.reserve(orchestration.ReservationRequest{CPULimit: 4}, now)
+	if perr != nil {
+		t.Fatalf("reserve: %s", perr.message)
+	}
+
+	without, _ := p.admission(createServerRequest{Template: "mc"}, now)
+	if without.Fits {
+		t.Fatalf("create fit without its reservation: %+v", without)
+	}
+	with, _ := p.admission(createServerRequest{Template: "mc", ReservationID: reservation.ID}, now)
+	if !with.Fits {
+		t.Fatalf("create did not fit with its reservation: %+v", with)
+	}
+
+	expired, _ := p.admission(createServerRequest{Template: "mc", ReservationID: reservation.ID}, now.Add(time.Hour))
+	if expired.Fits || admissionDim(t, expired, "reservation").Fits {
+		t.Fatalf("expired reservation admitted: %+v", expired)
+	}
+}
+
+func TestAdmissionReportsRenderFailures(t *testing.T) {
+	p := newTestAdmissionProvisioner()
+	p.templates["velocity"] = Template{
+		Name: "velocity",
+		Container: ContainerSpec{
+			Volumes: map[string]string{"/worlds/{{SERVER_ID}}": "/data"},
+			Ports:   []PortSpec{{Name: "proxy", Range: "25565-25566"}},
+		},
+		Files: []TemplateFile{{Path: "/data/velocity.toml", Content: "bind = \"0.0.0.0:{{PORT_PROXY}}\"\nsecret = \"{{env.SECRET}}\""}},
+	}
+	response, perr := p.admission(createServerRequest{Template: "velocity"}, time.Unix(1000, 0))
+	if perr != nil {
+		t.Fatalf("admission: %s", perr.message)
+	}
+	if dim := admissionDim(t, response, "template"); dim.Fits || response.Fits {
+		t.Fatalf("unknown {{env.SECRET}} admitted: %+v", response)
+	}
+
+	response, _ = p.admission(createServerRequest{Template: "velocity", Env: map[string]string{"SECRET": "s3"}}, time.Unix(1000, 0))
+	if !response.Fits {
+		t.Fatalf("admission = %+v", response)
+	}
+}
+
+func TestAdmissionUnknownTemplate(t *testing.T) {
+	p := newTestAdmissionProvisioner()
+	if _, perr := p.admission(createServerRequest{Template: "missing"}, time.Now()); perr == nil || perr.status != 404 {
+		t.Fatalf("perr = %+v", perr)
+	}
+}
+
+func TestPortAndIPAvailability(t *testing.T) {
+	ps := newPortPoolSet(newPortPool(6000, 6002))
+	ps.reserve(6001, "a")
+	ps.reserve(7000, "outside")
+	if free, _ := ps.available(""); free != 2 {
+		t.Fatalf("fallback free = %d, want 2", free)
+	}
+	if free, _ := ps.available("100-109"); free != 10 {
+		t.Fatalf("unseen range free = %d, want 10", free)
+	}
+	if _, err := ps.available("bad"); err == nil {
+		t.Fatal("invalid range accepted")
+	}
+
+	ipp, err := newIPPool("10.0.0.1", "10.0.0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipp.reserve("10.0.0.2", "a")
+	ipp.reserve("192.168.0.1", "outside")
+	if free := ipp.available(); free != 3 {
+		t.Fatalf("ip free = %d, want 3", free)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	bananaregistry "github.com/bananalabs-oss/bananagine/registry"
+)
+
+const adoptionsPath = "adoptions.json"
+
+// adoptRequest brings a container Bananagine did not create under management.
+// ServerID defaults to the container's Docker name. Register, when set, is
+// registered once the adoption holds its leases; ID, Host and Port default to
+// the server ID, the node's external host (else the container IP) and the
+// container's lowest published port.
+type adoptRequest struct {
+	Template string                 `json:"template"`
+	ServerID string                 `json:"server_id"`
+	Owner    string                 `json:"owner"`
+	Tier     string                 `json:"tier"`
+	Register *bananaregistry.Server `json:"register,omitempty"`
+}
+
+// adoption is the persisted record of one adopted container. Docker cannot
+// add labels to an existing container, so the labels live here and
+// provisioner.labeled overlays them wherever the cell reads labels.
+type adoption struct {
+	ContainerID string            `json:"container_id"`
+	ServerID    string            `json:"server_id"`
+	Labels      map[string]string `json:"labels"`
+	AdoptedAt   int64             `json:"adopted_at"`
+}
+
+// adoptContainer validates req against the container ref names, overlays the
+// ownership labels and leases the container's ports, IP, quota and capacity
+// like any server the cell created. On failure nothing is left behind except
+// the port and IP leases reconciliation already held for the container.
+func (p *provisioner) adoptContainer(ref string, req adoptRequest, now time.Time) (docker.Server, []string, *provisionError) {
+	if req.Template == "" {
+		return docker.Server{}, nil, &provisionError{status: 400, message: "template required"}
+	}
+	if _, ok := p.templates[req.Template]; !ok {
+		return docker.Server{}, nil, &provisionError{status: 404, message: "template not found"}
+	}
+	server, err := docker.Get(ref)
+	if err != nil {
+		if isDockerNotFound(err) {
+			return docker.Server{}, nil, &provisionError{status: 404, message: "container not found"}
+		}
+		return docker.Server{}, nil, provisionFailure(500, err)
+	}
+	if server == nil {
+		return docker.Server{}, nil, &provisionError{status: 404, message: "container not found"}
+	}
+	if p.labeled(*server).Labels[templateLabel] != "" {
+		return docker.Server{}, nil, &provisionError{status: 409, message: "container is already managed"}
+	}
+	serverID := req.ServerID
+	if serverID == "" {
+		serverID = strings.TrimPrefix(server.Name, "/")
+	}
+	for field, value := range map[string]string{"server_id": serverID, "owner": req.Owner, "tier": req.Tier} {
+		if value != "" && !validFleetIdentity(value) {
+			return docker.Server{}, nil, &provisionError{status: 400, message: "invalid " + field}
+		}
+	}
+	if bound, ok := p.bindings[serverID]; ok && bound != server.ID {
+		return docker.Server{}, nil, &provisionError{status: 409, message: "server_id is bound to another container"}
+	}
+	if err := p.quotas.admit(req.Owner, server.CPULimit, server.MemoryLimit); err != nil {
+		return docker.Server{}, nil, provisionFailure(403, err)
+	}
+
+	p.labels[server.ID] = serverLabels(req.Template, serverID, req.Owner, req.Tier)
+	kinds, err := p.adopt(*server, true)
+	if err != nil {
+		p.unadopt(server.ID, kinds)
+		return docker.Server{}, nil, provisionFailure(503, err)
+	}
+	if serverID != strings.TrimPrefix(server.Name, "/") {
+		p.bindings[serverID] = server.ID
+	}
+	p.adoptions[server.ID] = adoption{
+		ContainerID: server.ID,
+		ServerID:    serverID,
+		Labels:      p.labels[server.ID],
+		AdoptedAt:   now.Unix(),
+	}
+	return p.labeled(*server), kinds, nil
+}
+
+// unadopt reverses adoptContainer. Port and IP leases stay: they belong to the
+// container whether or not Bananagine manages it.
+func (p *provisioner) unadopt(containerID string, kinds []string) {
+	for _, kind := range kinds {
+		switch kind {
+		case "capacity":
+			p.capacity.release(containerID)
+		case "quota":
+			p.quotas.release(containerID)
+		}
+	}
+	if record, ok := p.adoptions[containerID]; ok && p.bindings[record.ServerID] == containerID {
+		delete(p.bindings, record.ServerID)
+	}
+	delete(p.adoptions, containerID)
+	delete(p.labels, containerID)
+}
+
+// loadAdoptions restores adoption labels and bindings for containers that
+// still exist. It runs before startup reconciliation so adopted containers
+// are charged capacity and quota like the cell's own.
+func (p *provisioner) loadAdoptions() error {
+	var records []adoption
+	if found, err := loadCellState(adoptionsPath, &records); err != nil || !found {
+		return err
+	}
+	for _, record := range records {
+		if containerGone(record.ContainerID) {
+			continue
+		}
+		p.adoptions[record.ContainerID] = record
+		p.labels[record.ContainerID] = record.Labels
+		if _, bound := p.bindings[record.ServerID]; !bound {
+			p.bindings[record.ServerID] = record.ContainerID
+		}
+	}
+	return nil
+}
+
+func (p *provisioner) storeAdoptions() error {
+	records := make([]adoption, 0, len(p.adoptions))
+	for _, record := range p.adoptions {
+		records = append(records, record)
+	}
+	return storeCellState(adoptionsPath, records)
+}
+
+// forgetAdoption drops a destroyed container's adoption record.
+func (p *provisioner) forgetAdoption(containerID string) {
+	if _, ok := p.adoptions[containerID]; !ok {
+		return
+	}
+	p.unadopt(containerID, nil)
+	if err := p.storeAdoptions(); err != nil {
+		log.Printf("[Adopt] persist adoptions: %v", err)
+	}
+}
+
+// adoptionRegistration fills the defaults documented on adoptRequest.
+func adoptionRegistration(server docker.Server, serverID, externalHost string, reg bananaregistry.Server) bananaregistry.Server {
+	if reg.ID == "" {
+		reg.ID = serverID
+	}
+	if reg.Host == "" {
+		reg.Host = externalHost
+		if reg.Host == "" {
+			reg.Host = server.IP
+		}
+	}
+	if reg.Port == 0 {
+		for _, port := range server.Ports {
+			if reg.Port == 0 || port < reg.Port {
+				reg.Port = port
+			}
+		}
+	}
+	return reg
+}
+
+func registerAdoptRoutes(group *pulpgin.RouterGroup, prov *provisioner) {
+	group.POST("/adopt/:container", func(c *pulpgin.Context) {
+		var req adoptRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		now := time.Now()
+		server, kinds, perr := prov.adoptContainer(c.Param("container"), req, now)
+		if perr != nil {
+			c.JSON(perr.status, pulpgin.H{"error": perr.message})
+			return
+		}
+		serverID := server.Labels[serverIDLabel]
+		if err := prov.storeAdoptions(); err != nil {
+			prov.unadopt(server.ID, kinds)
+			c.JSON(500, pulpgin.H{"error": fmt.Sprintf("persist adoption: %v", err)})
+			return
+		}
+		if req.Register != nil {
+			reg := adoptionRegistration(server, serverID, prov.cfg.ExternalHost, *req.Register)
+			result, err := callRegistry[bananaregistry.Server](bananaregistry.FnRegister, reg)
+			if err != nil || !result.OK {
+				prov.unadopt(server.ID, kinds)
+				if err := prov.storeAdoptions(); err != nil {
+					log.Printf("[Adopt] persist adoptions: %v", err)
+				}
+				if err != nil {
+					writeRegistryUnavailable(c, err)
+					return
+				}
+				writeRegistryFailure(c, bananaregistry.FnRegister, result.Error)
+				return
+			}
+		}
+		log.Printf("[Adopt] adopted %s as %s from template %s", server.ID, serverID, req.Template)
+		emitOrchestrationEvent(wireEvent{ContainerID: server.ID, Name: serverID, Action: "adopted", Time: now.UnixNano()})
+		c.JSON(201, toOrchestrationServer(server))
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	bananaregistry "github.com/bananalabs-oss/bananagine/registry"
+)
+
+func TestAdoptionLabelsMakeContainerManaged(t *testing.T) {
+	p := newTestReconcileProvisioner()
+	p.labels = make(map[string]map[string]string)
+	p.adoptions = make(map[string]adoption)
+	legacy := docker.Server{
+		ID:          "c9",
+		Name:        "/survival",
+		Ports:       map[string]int{"java": 6003},
+		CPULimit:    2,
+		MemoryLimit: 2 * gib,
+		Labels:      map[string]string{"com.example.stack": "old"},
+	}
+	if p.manages(legacy) {
+		t.Fatal("unlabelled legacy container reported as managed")
+	}
+
+	p.labels[legacy.ID] = serverLabels("mc", "survival-1", "acme", "")
+	kinds, err := p.adopt(legacy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"port", "quota", "capacity"}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("adopt kinds = %v, want %v", kinds, want)
+	}
+	labeled := p.labeled(legacy)
+	if labeled.Labels[templateLabel] != "mc" || labeled.Labels["com.example.stack"] != "old" {
+		t.Fatalf("labeled = %v", labeled.Labels)
+	}
+	if p.ownerOf(legacy) != "acme" || p.templateOf(legacy) != "mc" {
+		t.Fatalf("owner %q template %q", p.ownerOf(legacy), p.templateOf(legacy))
+	}
+
+	p.adoptions[legacy.ID] = adoption{ContainerID: legacy.ID, ServerID: "survival-1"}
+	p.bindings["survival-1"] = legacy.ID
+	p.unadopt(legacy.ID, kinds)
+	if _, held := p.capacity.containers[legacy.ID]; held {
+		t.Fatal("unadopt kept the capacity lease")
+	}
+	if _, charged := p.quotas.servers[legacy.ID]; charged {
+		t.Fatal("unadopt kept the quota charge")
+	}
+	if _, bound := p.bindings["survival-1"]; bound {
+		t.Fatal("unadopt kept the binding")
+	}
+	if _, held := p.portPools.poolFor(6003).allocated[6003]; !held {
+		t.Fatal("unadopt released the container's own port")
+	}
+	if p.manages(legacy) {
+		t.Fatal("container still managed after unadopt")
+	}
+}
+
+func TestAdoptionRegistrationDefaults(t *testing.T) {
+	server := docker.Server{IP: "10.0.0.3", Ports: map[string]int{"query": 6005, "java": 6002}}
+	reg := adoptionRegistration(server, "survival-1", "", bananaregistry.Server{Type: "game", Mode: "survival"})
+	if reg.ID != "survival-1" || reg.Host != "10.0.0.3" || reg.Port != 6002 || reg.Mode != "survival" {
+		t.Fatalf("registration = %+v", reg)
+	}
+	reg = adoptionRegistration(server, "survival-1", "play.example.com", bananaregistry.Server{ID: "s1", Port: 25565})
+	if reg.ID != "s1" || reg.Host != "play.example.com" || reg.Port != 25565 {
+		t.Fatalf("registration with overrides = %+v", reg)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+)
+
+const (
+	allocationLedgerPath    = "allocations.json"
+	portHistoryPath         = "port-history.json"
+	allocationStoreInterval = 5 * time.Second
+)
+
+// allocationLedger exposes and persists every lease held by the port pools,
+// the IP pool and capacityTracker. docker.List can miss stopped containers at
+// startup, so bootstrap restores their leases from the persisted ledger
+// instead of handing their ports and addresses to the next create.
+type allocationLedger struct {
+	capacity  *capacityTracker
+	ipp       *ipPoolSet
+	portPools *portPoolSet
+	// kept is the provisioner's set of holds a DELETE kept with keep_ports.
+	kept          map[string]struct{}
+	stored        []byte
+	storedHistory []byte
+	nextStore     time.Time
+}
+
+// allocationLease is one ledger row. Kind is "port", "ip" or "capacity";
+// ServerID is the allocator key, a container ID once a create commits.
+// StickyServerID is the server a port was allocated for; see ports.go. Kept
+// marks a port or IP hold a DELETE kept with keep_ports.
+type allocationLease struct {
+	Kind           string  `json:"kind"`
+	Network        string  `json:"network,omitempty"`
+	Pool           string  `json:"pool,omitempty"`
+	Port           int     `json:"port,omitempty"`
+	IP             string  `json:"ip,omitempty"`
+	ServerID       string  `json:"server_id"`
+	StickyServerID string  `json:"sticky_server_id,omitempty"`
+	CPU            float64 `json:"cpu,omitempty"`
+	MemoryGiB      float64 `json:"memory_gib,omitempty"`
+	Since          int64   `json:"since"`
+	AgeSeconds     int64   `json:"age_seconds,omitempty"`
+	Kept           bool    `json:"kept,omitempty"`
+}
+
+type allocationPool struct {
+	Kind    string `json:"kind"`
+	Network string `json:"network,omitempty"`
+	Pool    string `json:"pool"`
+	Size    int    `json:"size"`
+	Free    int    `json:"free"`
+	Leases  int    `json:"leases"`
+}
+
+type allocationCapacity struct {
+	CPUBudget          float64 `json:"cpu_budget"`
+	CPUAllocated       float64 `json:"cpu_allocated"`
+	MemoryBudgetGiB    float64 `json:"memory_budget_gib"`
+	MemoryAllocatedGiB float64 `json:"memory_allocated_gib"`
+}
+
+type allocationView struct {
+	Capacity allocationCapacity `json:"capacity"`
+	Pools    []allocationPool   `json:"pools"`
+	Leases   []allocationLease  `json:"leases"`
+}
+
+func newAllocationLedger(capacity *capacityTracker, ipp *ipPoolSet, portPools *portPoolSet, kept map[string]struct{}) *allocationLedger {
+	return &allocationLedger{capacity: capacity, ipp: ipp, portPools: portPools, kept: kept}
+}
+
+// leases returns every lease sorted by kind, pool and key. Reservation holds
+// are included for visibility but never persisted; see reservations.go.
+func (l *allocationLedger) leases() []allocationLease {
+	var result []allocationLease
+	for id, res := range l.capacity.containers {
+		result = append(result, allocationLease{
+			Kind: "capacity", ServerID: id, CPU: res.cpu, MemoryGiB: res.memGiB, Since: l.capacity.since[id],
+		})
+	}
+	for _, network := range l.ipp.names() {
+		for _, pool := range l.ipp.forNetwork(network).all() {
+			for ip, id := range pool.allocated {
+				_, kept := l.kept[id]
+				result = append(result, allocationLease{
+					Kind: "ip", Network: network, Pool: pool.name(), IP: ip, ServerID: id, Since: pool.since[ip], Kept: kept,
+				})
+			}
+		}
+	}
+	for _, pool := range l.portPools.all() {
+		for port, id := range pool.allocated {
+			_, kept := l.kept[id]
+			result = append(result, allocationLease{
+				Kind: "port", Pool: pool.name(), Port: port, ServerID: id, StickyServerID: pool.sticky[port], Since: pool.since[port], Kept: kept,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Network != b.Network {
+			return a.Network < b.Network
+		}
+		if a.Pool != b.Pool {
+			return a.Pool < b.Pool
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		return a.ServerID < b.ServerID
+	})
+	return result
+}
+
+func (l *allocationLedger) view(now time.Time) allocationView {
+	leases := l.leases()
+	for i := range leases {
+		if leases[i].Since > 0 {
+			leases[i].AgeSeconds = now.Unix() - leases[i].Since
+		}
+	}
+	var pools []allocationPool
+	for _, network := range l.ipp.names() {
+		for _, pool := range l.ipp.forNetwork(network).all() {
+			pools = append(pools, allocationPool{
+				Kind:    "ip",
+				Network: network,
+				Pool:    pool.name(),
+				Size:    pool.size(),
+				Free:    pool.available(),
+				Leases:  len(pool.allocated),
+			})
+		}
+	}
+	for _, pool := range l.portPools.all() {
+		pools = append(pools, allocationPool{
+			Kind:   "port",
+			Pool:   pool.name(),
+			Size:   pool.end - pool.start + 1,
+			Free:   pool.available(),
+			Leases: len(pool.allocated),
+		})
+	}
+	return allocationView{
+		Capacity: allocationCapacity{
+			CPUBudget:          l.capacity.cpuBudget,
+			CPUAllocated:       l.capacity.allocCPU,
+			MemoryBudgetGiB:    l.capacity.memBudget,
+			MemoryAllocatedGiB: l.capacity.allocMem,
+		},
+		Pools:  pools,
+		Leases: leases,
+	}
+}
+
+// store persists the ledger when it changed since the last write. It runs
+// from the step loop, throttled to allocationStoreInterval, so a burst of
+// creates costs one write.
+func (l *allocationLedger) store(now time.Time) {
+	if now.Before(l.nextStore) {
+		return
+	}
+	l.nextStore = now.Add(allocationStoreInterval)
+	var durable []allocationLease
+	for _, lease := range l.leases() {
+		if !strings.HasPrefix(lease.ServerID, reservationKey("")) {
+			durable = append(durable, lease)
+		}
+	}
+	if data, err := json.Marshal(durable); err == nil && !bytes.Equal(data, l.stored) {
+		if err := storeCellState(allocationLedgerPath, durable); err != nil {
+			log.Printf("[Allocations] persist ledger: %v", err)
+		} else {
+			l.stored = data
+		}
+	}
+	history := l.portPools.history()
+	if data, err := json.Marshal(history); err == nil && !bytes.Equal(data, l.storedHistory) {
+		if err := storeCellState(portHistoryPath, history); err != nil {
+			log.Printf("[Allocations] persist port history: %v", err)
+		} else {
+			l.storedHistory = data
+		}
+	}
+}
+
+// restore re-leases persisted entries that startup reconciliation did not
+// rebuild, as long as their container still exists or a DELETE kept them with
+// keep_ports. A lease for a port or address someone else already holds is
+// dropped. A port whose container went away while the cell was down counts as
+// released now, so it stays sticky to its server and cools down like any
+// other release.
+func (l *allocationLedger) restore() (int, error) {
+	var history []portHistory
+	if _, err := loadCellState(portHistoryPath, &history); err != nil {
+		return 0, err
+	}
+	l.portPools.restoreHistory(history)
+	var leases []allocationLease
+	if found, err := loadCellState(allocationLedgerPath, &leases); err != nil || !found {
+		return 0, err
+	}
+	return l.restoreLeases(leases, containerGone, time.Now()), nil
+}
+
+// restoreLeases is restore once the ledger is read; containerGone reports
+// whether a lease's container was removed.
+func (l *allocationLedger) restoreLeases(leases []allocationLease, containerGone func(string) bool, now time.Time) int {
+	gone := make(map[string]bool)
+	restored := 0
+	for _, lease := range leases {
+		if _, checked := gone[lease.ServerID]; !checked && !lease.Kept {
+			gone[lease.ServerID] = containerGone(lease.ServerID)
+		}
+		if gone[lease.ServerID] && !lease.Kept {
+			if lease.Kind == "port" {
+				l.portPools.restoreHistory([]portHistory{{Port: lease.Port, ServerID: lease.StickyServerID, ReleasedAt: now.Unix()}})
+			}
+			continue
+		}
+		switch lease.Kind {
+		case "capacity":
+			if _, held := l.capacity.containers[lease.ServerID]; held {
+				continue
+			}
+			l.capacity.restore(lease.ServerID, lease.CPU, lease.MemoryGiB, lease.Since)
+		case "ip":
+			pool := l.ipp.forNetwork(lease.Network).poolFor(lease.IP)
+			if _, held := pool.allocated[lease.IP]; held {
+				continue
+			}
+			pool.restore(lease.IP, lease.ServerID, lease.Since)
+		case "port":
+			pool := l.portPools.poolFor(lease.Port)
+			if _, held := pool.allocated[lease.Port]; held {
+				continue
+			}
+			pool.restore(lease.Port, lease.ServerID, lease.Since)
+			if lease.StickyServerID != "" {
+				pool.sticky[lease.Port] = lease.StickyServerID
+			}
+		default:
+			continue
+		}
+		if lease.Kept {
+			l.kept[lease.ServerID] = struct{}{}
+		}
+		restored++
+	}
+	return restored
+}
+
+func registerAllocationRoutes(group *pulpgin.RouterGroup, ledger *allocationLedger) {
+	group.GET("/allocations", func(c *pulpgin.Context) {
+		c.JSON(200, ledger.view(time.Now()))
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllocationLedgerView(t *testing.T) {
+	capacity := newCapacityTracker(8, 16)
+	ipp := mustIPPoolSet("10.0.0.1", "10.0.0.4")
+	portPools := newPortPoolSet(newPortPool(6000, 6009))
+	ledger := newAllocationLedger(capacity, ipp, portPools, map[string]struct{}{})
+
+	capacity.restore("c1", 2, 4, 100)
+	ipp.forNetwork("").poolFor("10.0.0.2").restore("10.0.0.2", "c1", 100)
+	portPools.poolFor(6003).restore(6003, "c1", 150)
+	if _, err := portPools.allocate("25565-25566", "c2"); err != nil {
+		t.Fatal(err)
+	}
+
+	view := ledger.view(time.Unix(200, 0))
+	if view.Capacity.CPUAllocated != 2 || view.Capacity.MemoryAllocatedGiB != 4 {
+		t.Fatalf("capacity = %+v", view.Capacity)
+	}
+	if len(view.Pools) != 3 {
+		t.Fatalf("pools = %+v", view.Pools)
+	}
+	if ip := view.Pools[0]; ip.Kind != "ip" || ip.Size != 4 || ip.Free != 3 || ip.Leases != 1 {
+		t.Fatalf("ip pool = %+v", ip)
+	}
+	if fallback := view.Pools[1]; fallback.Pool != "6000-6009" || fallback.Free != 9 {
+		t.Fatalf("fallback pool = %+v", fallback)
+	}
+	if ranged := view.Pools[2]; ranged.Pool != "25565-25566" || ranged.Free != 1 {
+		t.Fatalf("range pool = %+v", ranged)
+	}
+
+	if len(view.Leases) != 4 {
+		t.Fatalf("leases = %+v", view.Leases)
+	}
+	first := view.Leases[0]
+	if first.Kind != "capacity" || first.ServerID != "c1" || first.AgeSeconds != 100 {
+		t.Fatalf("capacity lease = %+v", first)
+	}
+	for _, lease := range view.Leases {
+		if lease.Kind == "port" && lease.Port == 6003 && lease.AgeSeconds != 50 {
+			t.Fatalf("port lease age = %d, want 50", lease.AgeSeconds)
+		}
+	}
+}
+
+func TestAllocatorsTrackLeaseTimes(t *testing.T) {
+	capacity := newCapacityTracker(0, 0)
+	if err := capacity.tryAllocate("pending", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	capacity.commit("pending", "container-1")
+	if capacity.since["container-1"] == 0 || capacity.since["pending"] != 0 {
+		t.Fatalf("since after commit = %+v", capacity.since)
+	}
+	capacity.release("container-1")
+	if len(capacity.since) != 0 {
+		t.Fatalf("since after release = %+v", capacity.since)
+	}
+
+	ports := newPortPool(7000, 7001)
+	port, _ := ports.allocate("s1")
+	if ports.since[port] == 0 {
+		t.Fatal("port lease time not recorded")
+	}
+	ports.releaseByServer("s1")
+	if len(ports.since) != 0 {
+		t.Fatalf("port since after release = %+v", ports.since)
+	}
+}
+
+func TestAllocationLedgerRestoresKeptHolds(t *testing.T) {
+	capacity := newCapacityTracker(8, 16)
+	ipp := mustIPPoolSet("10.0.0.1", "10.0.0.4")
+	portPools := newPortPoolSet(newPortPool(6000, 6009))
+	kept := map[string]struct{}{}
+	ledger := newAllocationLedger(capacity, ipp, portPools, kept)
+	for _, id := range []string{"c1", "c2"} {
+		if _, err := portPools.allocate("", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// DELETE c1?keep_ports=1&server_id=mc-next before the cell restarts.
+	portPools.reKey("c1", "mc-next")
+	kept["mc-next"] = struct{}{}
+	leases := ledger.leases()
+	for _, lease := range leases {
+		if lease.Kept != (lease.ServerID == "mc-next") {
+			t.Fatalf("lease %+v", lease)
+		}
+	}
+
+	restartedPorts := newPortPoolSet(newPortPool(6000, 6009))
+	restartedKept := map[string]struct{}{}
+	restarted := newAllocationLedger(newCapacityTracker(8, 16), mustIPPoolSet("10.0.0.1", "10.0.0.4"), restartedPorts, restartedKept)
+	gone := func(string) bool { return true }
+	if restored := restarted.restoreLeases(leases, gone, time.Unix(200, 0)); restored != 1 {
+		t.Fatalf("restored = %d, want 1", restored)
+	}
+	var owners []string
+	for _, lease := range restarted.leases() {
+		owners = append(owners, lease.ServerID)
+	}
+	if len(owners) != 1 || owners[0] != "mc-next" {
+		t.Fatalf("restored holders = %v", owners)
+	}
+	if _, ok := restartedKept["mc-next"]; !ok {
+		t.Fatal("restored hold is no longer marked kept")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/MonkeyLabs-LLC/Marrow/capacity"
 )
@@ -20,6 +21,7 @@ type capacityTracker struct {
 	allocCPU   float64
 	allocMem   float64 // GiB
 	containers map[string]struct{ cpu, memGiB float64 }
+	since      map[string]int64 // container ID -> unix second it was admitted
 }
 
 func newCapacityTracker(cpuBudget, memBudget float64) *capacityTracker {
@@ -27,6 +29,7 @@ func newCapacityTracker(cpuBudget, memBudget float64) *capacityTracker {
 		cpuBudget:  cpuBudget,
 		memBudget:  memBudget,
 		containers: make(map[string]struct{ cpu, memGiB float64 }),
+		since:      make(map[string]int64),
 	}
 }
 
@@ -42,16 +45,25 @@ func (ct *capacityTracker) tryAllocate(containerID string, cpuLimit float64, mem
 		}
 		return fmt.Errorf("memory capacity exceeded (%.2f + %.2f > %.2f GiB)", ct.allocMem, memGiB, ct.memBudget)
 	}
+	ct.restore(containerID, cpuLimit, memGiB, time.Now().Unix())
+	return nil
+}
+
+// restore admits a lease rebuilt from the persisted allocation ledger without
+// a budget check: the container already holds the room it was admitted with.
+func (ct *capacityTracker) restore(containerID string, cpuLimit, memGiB float64, since int64) {
 	ct.allocCPU += cpuLimit
 	ct.allocMem += memGiB
 	ct.containers[containerID] = struct{ cpu, memGiB float64 }{cpuLimit, memGiB}
-	return nil
+	ct.since[containerID] = since
 }
 
 func (ct *capacityTracker) commit(tempID, realID string) {
 	if res, ok := ct.containers[tempID]; ok {
 		delete(ct.containers, tempID)
 		ct.containers[realID] = res
+		ct.since[realID] = ct.since[tempID]
+		delete(ct.since, tempID)
 	}
 }
 
@@ -60,6 +72,7 @@ func (ct *capacityTracker) release(containerID string) {
 		ct.allocCPU -= res.cpu
 		ct.allocMem -= res.memGiB
 		delete(ct.containers, containerID)
+		delete(ct.since, containerID)
 	}
 }
 
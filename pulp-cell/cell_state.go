@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp"
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+)
+
+// loadCellState decodes one JSON state file from the cell's scoped storage. A
+// missing file is not an error; it reports found=false so callers start empty.
+func loadCellState(path string, target any) (bool, error) {
+	wire, err := pulp.FS.Read(path)
+	if err != nil {
+		if errors.Is(err, pulp.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(wire, target); err != nil {
+		return false, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// storeCellState replaces one JSON state file atomically through a temporary
+// sibling, matching the fleet receipt writers.
+func storeCellState(path string, value any) error {
+	wire, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := pulp.FS.WriteMode(temp, wire, 0o600); err != nil {
+		return err
+	}
+	return pulp.FS.Rename(temp, path)
+}
+
+// containerGone reports only a definite not-found, so a transient Docker error
+// on startup keeps a persisted entry rather than leaking its leases.
+func containerGone(containerID string) bool {
+	_, err := docker.Get(containerID)
+	return isDockerNotFound(err)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp"
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+)
+
+const defaultPropertiesFile = "/data/server.properties"
+
+// settingsPlan is where a settings map lands once translated through the
+// options' targets. Keys without a target stay in the caller env untouched.
+//
+// On create, env targets become container env vars, gamerule targets become
+// FLEET_GAMERULE_<rule> env vars (which the game image applies at boot, like
+// a caller-supplied gamerule), and property edits are written into the
+// properties file on the server's volume before the container is created.
+// On reconfigure, gamerules are applied over rcon and property edits are
+// written in place; they take effect on the next restart. An env target
+// cannot change on a running container, so reconfigure rejects it.
+type settingsPlan struct {
+	env        map[string]string
+	properties map[string]string
+	gameRules  map[string]string
+	// propertiesFile is the container path of the properties file.
+	propertiesFile string
+}
+
+func (p settingsPlan) empty() bool {
+	return len(p.env) == 0 && len(p.properties) == 0 && len(p.gameRules) == 0
+}
+
+// translateSettings splits env into the plan for targeted options and the
+// remaining env. Values were already validated against the schema; the
+// target checks here only keep a value from escaping its destination.
+func translateSettings(schema ConfigSchema, env map[string]string) (settingsPlan, map[string]string, error) {
+	plan := settingsPlan{
+		env:            make(map[string]string),
+		properties:     make(map[string]string),
+		gameRules:      make(map[string]string),
+		propertiesFile: schema.PropertiesFile,
+	}
+	if plan.propertiesFile == "" {
+		plan.propertiesFile = defaultPropertiesFile
+	}
+	rest := make(map[string]string, len(env))
+	for key, value := range env {
+		var option ConfigOption
+		var ok bool
+		switch {
+		case strings.HasPrefix(key, settingEnvPrefix):
+			option, ok = schema.Settings[strings.TrimPrefix(key, settingEnvPrefix)]
+		case strings.HasPrefix(key, gameRuleEnvPrefix):
+			option, ok = schema.GameRules[strings.TrimPrefix(key, gameRuleEnvPrefix)]
+		}
+		if !ok || option.Target == nil {
+			rest[key] = value
+			continue
+		}
+		target := option.Target
+		if target.Env != "" {
+			if !validVariableName(target.Env) || strings.Contains(target.Env, ".") {
+				return settingsPlan{}, nil, fmt.Errorf("%s targets invalid env var %q", key, target.Env)
+			}
+			plan.env[target.Env] = value
+		}
+		if target.Property != "" {
+			if !validFleetToken(target.Property, 128) || strings.ContainsAny(value, "\r\n") {
+				return settingsPlan{}, nil, fmt.Errorf("%s cannot be written to property %q", key, target.Property)
+			}
+			plan.properties[target.Property] = value
+		}
+		if target.GameRule != "" {
+			if !validFleetToken(target.GameRule, 64) || !validFleetRuleValue(value) {
+				return settingsPlan{}, nil, fmt.Errorf("%s cannot be applied as gamerule %q", key, target.GameRule)
+			}
+			plan.gameRules[target.GameRule] = value
+		}
+	}
+	return plan, rest, nil
+}
+
+// applyCreate merges the plan into a container about to be created and
+// returns the host path its property edits go to, empty when it has none.
+// Nothing is written: create calls writeCreateProperties once the server has
+// been admitted, so a rejected create leaves the volume untouched.
+func (plan settingsPlan) applyCreate(container *ContainerSpec) (string, error) {
+	for key, value := range plan.env {
+		container.Environment[key] = value
+	}
+	for rule, value := range plan.gameRules {
+		container.Environment[gameRuleEnvPrefix+rule] = value
+	}
+	if len(plan.properties) == 0 {
+		return "", nil
+	}
+	path, ok := volumeHostPath(container.Volumes, plan.propertiesFile)
+	if !ok {
+		return "", fmt.Errorf("no volume holds %s", plan.propertiesFile)
+	}
+	return path, nil
+}
+
+// writeCreateProperties writes the plan's property edits to the path
+// applyCreate returned.
+func (plan settingsPlan) writeCreateProperties(path string) error {
+	if path == "" {
+		return nil
+	}
+	return writeProperties(path, plan.properties)
+}
+
+// reconfigurePlan translates a reconfigure env for a server of tmpl and
+// returns the plan plus the rcon commands for keys without a target, which
+// still go through the fixed allowlist.
+func reconfigurePlan(tmpl Template, env map[string]string) (settingsPlan, [][]string, error) {
+	if _, fields := validateCallerSettings(tmpl, env); len(fields) > 0 {
+		messages := make([]string, len(fields))
+		for i, field := range fields {
+			messages[i] = field.Message
+		}
+		return settingsPlan{}, nil, errors.New(strings.Join(messages, "; "))
+	}
+	plan, rest, err := translateSettings(tmpl.Config, env)
+	if err != nil {
+		return settingsPlan{}, nil, err
+	}
+	if len(plan.env) > 0 {
+		keys := make([]string, 0, len(plan.env))
+		for key := range plan.env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return settingsPlan{}, nil, fmt.Errorf("env %s cannot change on a running server", strings.Join(keys, ", "))
+	}
+	commands, err := fleetReconfigureCommands(rest)
+	if err != nil {
+		return settingsPlan{}, nil, err
+	}
+	return plan, commands, nil
+}
+
+// reconfigureSettings is the bootstrap's fleetReconfigureSettings: it finds
+// the server's template and builds its reconfigure plan, which also
+// re-renders the template's files. A server without a loaded template gets
+// the fixed allowlist only.
+func (p *provisioner) reconfigureSettings(containerID string, env map[string]string) (func() error, [][]string, error) {
+	if len(env) == 0 {
+		return nil, nil, nil
+	}
+	server, err := docker.Get(containerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl, ok := p.templates[p.templateOf(*server)]
+	if !ok {
+		commands, err := fleetReconfigureCommands(env)
+		return nil, commands, err
+	}
+	plan, commands, err := reconfigurePlan(tmpl, env)
+	if err != nil || (plan.empty() && len(tmpl.Files) == 0) {
+		return nil, commands, err
+	}
+	var propertiesPath string
+	if len(plan.properties) > 0 {
+		volumes := serverVolumes(tmpl, strings.TrimPrefix(server.Name, "/"), p.cfg.ExternalHost)
+		path, ok := volumeHostPath(volumes, plan.propertiesFile)
+		if !ok {
+			return nil, nil, fmt.Errorf("cannot locate %s on the host", plan.propertiesFile)
+		}
+		propertiesPath = path
+	}
+	apply := func() error {
+		if propertiesPath != "" {
+			if err := writeProperties(propertiesPath, plan.properties); err != nil {
+				return fmt.Errorf("write %s: %w", plan.propertiesFile, err)
+			}
+		}
+		rules := make([]string, 0, len(plan.gameRules))
+		for rule := range plan.gameRules {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		for _, rule := range rules {
+			if _, err := docker.Exec(containerID, []string{"rcon", "gamerule " + rule + " " + plan.gameRules[rule]}); err != nil {
+				return fmt.Errorf("apply gamerule %s: %w", rule, err)
+			}
+		}
+		return p.rerenderFiles(containerID, tmpl, env)
+	}
+	return apply, commands, nil
+}
+
+// serverVolumes re-derives a running server's volumes from its template. Only
+// the variables known without the create request (SERVER_ID, TEMPLATE and
+// node.external_host) resolve; a volume needing anything else is left out.
+func serverVolumes(tmpl Template, serverID, externalHost string) map[string]string {
+	vars := map[string]string{"SERVER_ID": serverID, "TEMPLATE": tmpl.Name}
+	if externalHost != "" {
+		vars["node.external_host"] = externalHost
+	}
+	volumes := make(map[string]string, len(tmpl.Container.Volumes))
+	for hostPath, containerPath := range tmpl.Container.Volumes {
+		host, err := interpolate(hostPath, vars)
+		if err != nil {
+			continue
+		}
+		target, err := interpolate(containerPath, vars)
+		if err != nil {
+			continue
+		}
+		volumes[host] = target
+	}
+	return volumes
+}
+
+// volumeHostPath maps a container path to its cell-FS path through the
+// deepest volume that holds it. Host paths are scoped like worlds_dir: the
+// leading slash is dropped and the operator mounts them under the cell's
+// storage root.
+func volumeHostPath(volumes map[string]string, containerPath string) (string, bool) {
+	best, bestLen := "", -1
+	for hostPath, mount := range volumes {
+		mount = strings.TrimSuffix(mount, "/")
+		if containerPath != mount && !strings.HasPrefix(containerPath, mount+"/") {
+			continue
+		}
+		if len(mount) > bestLen {
+			best, bestLen = strings.Trim(hostPath, "/")+strings.TrimPrefix(containerPath, mount), len(mount)
+		}
+	}
+	if bestLen < 0 || strings.Contains(best, "..") {
+		return "", false
+	}
+	return best, true
+}
+
+// writeProperties applies edits to the properties file at path, creating it
+// when missing.
+func writeProperties(path string, edits map[string]string) error {
+	data, err := pulp.FS.Read(path)
+	if err != nil && !errors.Is(err, pulp.ErrNotFound) {
+		return err
+	}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		if err := pulp.FS.MkdirAll(path[:i], 0o755); err != nil {
+			return err
+		}
+	}
+	return pulp.FS.Write(path, editProperties(data, edits))
+}
+
+// volumeSnapshot holds what a create's volume writes replace, so a create
+// that fails or loses a race to another request can put the files back.
+type volumeSnapshot []savedFile
+
+type savedFile struct {
+	path    string
+	data    []byte
+	existed bool
+}
+
+// snapshotFiles reads each non-empty path before it is written.
+func snapshotFiles(paths ...string) (volumeSnapshot, error) {
+	var snapshot volumeSnapshot
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		data, err := pulp.FS.Read(path)
+		if err != nil && !errors.Is(err, pulp.ErrNotFound) {
+			return nil, err
+		}
+		snapshot = append(snapshot, savedFile{path: path, data: data, existed: err == nil})
+	}
+	return snapshot, nil
+}
+
+// restore writes every file back as it was, removing the ones that did not
+// exist. Later writes are undone first, so a path snapshotted twice ends up
+// as it was before the first.
+func (s volumeSnapshot) restore() {
+	for i := len(s) - 1; i >= 0; i-- {
+		file := s[i]
+		var err error
+		if file.existed {
+			err = pulp.FS.Write(file.path, file.data)
+		} else if err = pulp.FS.Remove(file.path); errors.Is(err, pulp.ErrNotFound) {
+			err = nil
+		}
+		if err != nil {
+			log.Printf("[Provision] restore %s: %v", file.path, err)
+		}
+	}
+}
+
+// editProperties sets each key in a Java properties file, replacing the
+// line that assigns it and appending keys it does not have in sorted order.
+// Comments and unrelated lines are kept as they are.
+func editProperties(data []byte, edits map[string]string) []byte {
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	done := make(map[string]bool, len(edits))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!' {
+			continue
+		}
+		key := trimmed
+		if end := strings.IndexAny(trimmed, "=:"); end >= 0 {
+			key = strings.TrimSpace(trimmed[:end])
+		}
+		if value, ok := edits[key]; ok {
+			lines[i] = key + "=" + value
+			done[key] = true
+		}
+	}
+	keys := make([]string, 0, len(edits))
+	for key := range edits {
+		if !done[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, key+"="+edits[key])
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func targetedSchema() ConfigSchema {
+	return ConfigSchema{
+		Settings: map[string]ConfigOption{
+			"difficulty": {Type: "select", Options: []string{"easy", "hard"}, Target: &OptionTarget{Property: "difficulty"}},
+			"motd":       {Type: "text", Target: &OptionTarget{Env: "MOTD", Property: "motd"}},
+			"gamemode":   {Type: "select", Options: []string{"survival", "creative"}},
+		},
+		GameRules: map[string]ConfigOption{
+			"keep_inventory": {Type: "boolean", Target: &OptionTarget{GameRule: "keepInventory"}},
+		},
+	}
+}
+
+func TestTranslateSettings(t *testing.T) {
+	plan, rest, err := translateSettings(targetedSchema(), map[string]string{
+		"FLEET_SETTING_difficulty":      "hard",
+		"FLEET_SETTING_motd":            "Welcome",
+		"FLEET_SETTING_gamemode":        "creative",
+		"FLEET_GAMERULE_keep_inventory": "true",
+		"TYPE":                          "PAPER",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.env, map[string]string{"MOTD": "Welcome"}) ||
+		!reflect.DeepEqual(plan.properties, map[string]string{"difficulty": "hard", "motd": "Welcome"}) ||
+		!reflect.DeepEqual(plan.gameRules, map[string]string{"keepInventory": "true"}) ||
+		plan.propertiesFile != defaultPropertiesFile {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if want := map[string]string{"FLEET_SETTING_gamemode": "creative", "TYPE": "PAPER"}; !reflect.DeepEqual(rest, want) {
+		t.Fatalf("rest = %v", rest)
+	}
+
+	container := ContainerSpec{Environment: map[string]string{}, Volumes: map[string]string{"/worlds/mc-1": "/data"}}
+	path, err := plan.applyCreate(&container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "worlds/mc-1/server.properties" {
+		t.Fatalf("properties path = %q", path)
+	}
+	if container.Environment["MOTD"] != "Welcome" || container.Environment["FLEET_GAMERULE_keepInventory"] != "true" {
+		t.Fatalf("environment = %v", container.Environment)
+	}
+
+	if _, _, err := translateSettings(targetedSchema(), map[string]string{"FLEET_SETTING_motd": "a\nop=true"}); err == nil {
+		t.Fatal("accepted a property value with a newline")
+	}
+}
+
+func TestReconfigurePlanRejectsEnvTargets(t *testing.T) {
+	tmpl := Template{Config: targetedSchema()}
+	plan, commands, err := reconfigurePlan(tmpl, map[string]string{
+		"FLEET_SETTING_difficulty":      "easy",
+		"FLEET_SETTING_gamemode":        "creative",
+		"FLEET_GAMERULE_keep_inventory": "false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.properties["difficulty"] != "easy" || plan.gameRules["keepInventory"] != "false" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if want := [][]string{{"rcon", "defaultgamemode creative"}}; !reflect.DeepEqual(commands, want) {
+		t.Fatalf("commands = %v", commands)
+	}
+	if _, _, err := reconfigurePlan(tmpl, map[string]string{"FLEET_SETTING_motd": "hi"}); err == nil || !strings.Contains(err.Error(), "MOTD") {
+		t.Fatalf("env target error = %v", err)
+	}
+	if _, _, err := reconfigurePlan(tmpl, map[string]string{"FLEET_SETTING_difficulty": "brutal"}); err == nil {
+		t.Fatal("accepted a value outside the schema")
+	}
+}
+
+func TestVolumeHostPath(t *testing.T) {
+	volumes := serverVolumes(Template{Name: "paper", Container: ContainerSpec{Volumes: map[string]string{
+		"/var/sessions/worlds/{{SERVER_ID}}": "/data",
+		"/var/sessions/config/{{TEMPLATE}}":  "/data/config",
+		"/var/cache/{{env.VERSION}}":         "/cache",
+	}}}, "mc-1", "")
+	if len(volumes) != 2 {
+		t.Fatalf("volumes = %v", volumes)
+	}
+	for containerPath, want := range map[string]string{
+		"/data/server.properties":     "var/sessions/worlds/mc-1/server.properties",
+		"/data/config/bukkit.yml":     "var/sessions/config/paper/bukkit.yml",
+		"/database/server.properties": "",
+	} {
+		got, ok := volumeHostPath(volumes, containerPath)
+		if got != want || ok != (want != "") {
+			t.Errorf("volumeHostPath(%s) = %q, %v; want %q", containerPath, got, ok, want)
+		}
+	}
+}
+
+func TestEditProperties(t *testing.T) {
+	got := editProperties([]byte("#Minecraft server properties\ndifficulty=easy\nmotd = old\npvp=true\n"),
+		map[string]string{"difficulty": "hard", "motd": "new", "max-players": "10"})
+	want := "#Minecraft server properties\ndifficulty=hard\nmotd=new\npvp=true\nmax-players=10\n"
+	if string(got) != want {
+		t.Fatalf("edited =\n%s\nwant\n%s", got, want)
+	}
+	if got := editProperties(nil, map[string]string{"b": "2", "a": "1"}); string(got) != "a=1\nb=2\n" {
+		t.Fatalf("new file = %q", got)
+	}
+}
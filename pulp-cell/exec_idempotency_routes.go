@@ -51,6 +51,7 @@ func fleetExecV2Handler(c *pulpgin.Context) {
 		c.JSON(400, pulpgin.H{"error": "invalid container identity"})
 		return
 	}
+	containerID = fleetResolveContainer(containerID)
 	request, err := decodeFleetExecV2Request(c)
 	if err != nil {
 		c.JSON(400, pulpgin.H{"error": err.Error()})
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// resolveTemplates flattens every template's extends and mixins, returning
+// the servable (non-abstract) templates and an error for each template that
+// could not be resolved: a missing parent or mixin, or a cycle.
+//
+// A template is built by starting from its extends parent (itself resolved),
+// applying each mixin in order, then applying the template's own fields.
+// Every layer overrides the result so far with mergeTemplate:
+//
+//   - scalars and the hook URL are replaced when the layer sets them
+//     (non-zero); a layer cannot unset a field it inherits
+//   - maps (environment, volumes, server, settings, game_rules) are merged
+//     key by key, the layer winning
+//   - ports are merged by name, a layer's port replacing the inherited port
+//     of the same name in place; unnamed ports are appended
+//   - engines merge by value, extensions by option and files by path, the
+//     same way
+//   - idle is replaced when the layer sets it
+//
+// Name and Abstract never inherit.
+func resolveTemplates(raw map[string]Template) (map[string]Template, map[string]error) {
+	r := templateResolver{
+		raw:      raw,
+		resolved: make(map[string]Template, len(raw)),
+		failed:   make(map[string]error),
+		visiting: make(map[string]bool),
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	templates := make(map[string]Template, len(raw))
+	errs := make(map[string]error)
+	for _, name := range names {
+		t, err := r.resolve(name, nil)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		if !t.Abstract {
+			templates[name] = t
+		}
+	}
+	return templates, errs
+}
+
+type templateResolver struct {
+	raw      map[string]Template
+	resolved map[string]Template
+	failed   map[string]error
+	visiting map[string]bool
+}
+
+// resolve flattens name. chain is the path of templates being resolved that
+// led here, for the cycle error.
+func (r *templateResolver) resolve(name string, chain []string) (Template, error) {
+	if t, ok := r.resolved[name]; ok {
+		return t, nil
+	}
+	if err, ok := r.failed[name]; ok {
+		return Template{}, err
+	}
+	chain = append(chain, name)
+	if r.visiting[name] {
+		return Template{}, fmt.Errorf("inheritance cycle %s", strings.Join(chain, " -> "))
+	}
+	t, ok := r.raw[name]
+	if !ok {
+		return Template{}, fmt.Errorf("template %q not found", name)
+	}
+	if t.Extends == "" && len(t.Mixins) == 0 {
+		r.resolved[name] = t
+		return t, nil
+	}
+
+	r.visiting[name] = true
+	defer delete(r.visiting, name)
+	var out Template
+	for i, parent := range append([]string{t.Extends}, t.Mixins...) {
+		if parent == "" {
+			continue
+		}
+		base, err := r.resolve(parent, chain)
+		if err != nil {
+			kind := "mixin"
+			if i == 0 {
+				kind = "extends"
+			}
+			err = fmt.Errorf("%s %s: %w", kind, parent, err)
+			r.failed[name] = err
+			return Template{}, err
+		}
+		out = mergeTemplate(out, base)
+	}
+	out = mergeTemplate(out, t)
+	out.Name = t.Name
+	out.Abstract = t.Abstract
+	out.Extends, out.Mixins = "", nil
+	r.resolved[name] = out
+	return out, nil
+}
+
+// mergeTemplate returns base overridden by layer; see resolveTemplates.
+// Neither argument is modified.
+func mergeTemplate(base, layer Template) Template {
+	out := base
+	out.Game = pick(base.Game, layer.Game)
+	out.Label = pick(base.Label, layer.Label)
+	out.Engine = pick(base.Engine, layer.Engine)
+	out.Hooks.PreStart = pick(base.Hooks.PreStart, layer.Hooks.PreStart)
+	out.WarmPool = pick(base.WarmPool, layer.WarmPool)
+	if layer.Idle != nil {
+		idle := *layer.Idle
+		out.Idle = &idle
+	}
+	out.Server = mergeMaps(base.Server, layer.Server)
+	out.Files = mergeKeyed(base.Files, layer.Files, func(f TemplateFile) string { return f.Path })
+
+	bc, lc := base.Container, layer.Container
+	out.Container = deepCopyContainer(bc)
+	out.Container.Image = pick(bc.Image, lc.Image)
+	out.Container.Network = pick(bc.Network, lc.Network)
+	out.Container.CPULimit = pick(bc.CPULimit, lc.CPULimit)
+	out.Container.MemoryLimit = pick(bc.MemoryLimit, lc.MemoryLimit)
+	out.Container.DiskIOReadBps = pick(bc.DiskIOReadBps, lc.DiskIOReadBps)
+	out.Container.DiskIOWriteBps = pick(bc.DiskIOWriteBps, lc.DiskIOWriteBps)
+	out.Container.DiskSizeLimit = pick(bc.DiskSizeLimit, lc.DiskSizeLimit)
+	out.Container.PidsLimit = pick(bc.PidsLimit, lc.PidsLimit)
+	out.Container.MemorySwap = pick(bc.MemorySwap, lc.MemorySwap)
+	out.Container.Environment = mergeMaps(bc.Environment, lc.Environment)
+	out.Container.Volumes = mergeMaps(bc.Volumes, lc.Volumes)
+	out.Container.Ports = mergeKeyed(bc.Ports, lc.Ports, func(p PortSpec) string { return p.Name })
+
+	out.Config.Settings = mergeMaps(base.Config.Settings, layer.Config.Settings)
+	out.Config.GameRules = mergeMaps(base.Config.GameRules, layer.Config.GameRules)
+	out.Config.Engines = mergeKeyed(base.Config.Engines, layer.Config.Engines, func(e EngineOption) string { return e.Value })
+	out.Config.PropertiesFile = pick(base.Config.PropertiesFile, layer.Config.PropertiesFile)
+	out.Config.Extensions = mergeKeyed(base.Config.Extensions, layer.Config.Extensions, func(e PlatformExtension) string { return e.Option })
+	return out
+}
+
+// pick returns layer unless it is the zero value.
+func pick[T comparable](base, layer T) T {
+	var zero T
+	if layer != zero {
+		return layer
+	}
+	return base
+}
+
+func mergeMaps[V any](base, layer map[string]V) map[string]V {
+	if base == nil && layer == nil {
+		return nil
+	}
+	out := make(map[string]V, len(base)+len(layer))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range layer {
+		out[k] = v
+	}
+	return out
+}
+
+// mergeKeyed replaces base items whose key matches a layer item, in place,
+// and appends the rest of layer. Items with an empty key are always appended.
+func mergeKeyed[T any](base, layer []T, key func(T) string) []T {
+	if base == nil && layer == nil {
+		return nil
+	}
+	out := make([]T, len(base), len(base)+len(layer))
+	copy(out, base)
+	index := make(map[string]int, len(base))
+	for i, item := range base {
+		if k := key(item); k != "" {
+			index[k] = i
+		}
+	}
+	for _, item := range layer {
+		if i, ok := index[key(item)]; ok {
+			out[i] = item
+			continue
+		}
+		if k := key(item); k != "" {
+			index[k] = len(out)
+		}
+		out = append(out, item)
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseTemplates(t *testing.T, docs ...string) map[string]Template {
+	t.Helper()
+	raw := make(map[string]Template)
+	for _, doc := range docs {
+		var tmpl Template
+		if err := yaml.Unmarshal([]byte(doc), &tmpl); err != nil {
+			t.Fatal(err)
+		}
+		raw[tmpl.Name] = tmpl
+	}
+	return raw
+}
+
+func TestResolveTemplatesExtendsAndMixins(t *testing.T) {
+	raw := parseTemplates(t, `
+name: mc-base
+abstract: true
+game: minecraft
+container:
+  image: itzg/minecraft-server:latest
+  cpu_limit: 2
+  environment: {EULA: "TRUE", TYPE: VANILLA}
+  ports:
+    - {container: 25565, name: java, range: "25565-25599"}
+    - {container: 25575, name: rcon}
+config:
+  engines:
+    - {value: vanilla, label: Vanilla}
+`, `
+name: big
+abstract: true
+container:
+  memory_limit: 8589934592
+  environment: {INIT_MEMORY: 6G}
+`, `
+name: paper
+extends: mc-base
+mixins: [big]
+label: Paper
+container:
+  environment: {TYPE: PAPER}
+  ports:
+    - {container: 25566, name: java, range: "26000-26099"}
+    - {container: 8100, name: map}
+config:
+  engines:
+    - {value: vanilla, label: Vanilla (Paper)}
+    - {value: paper, label: Paper}
+`)
+	templates, errs := resolveTemplates(raw)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if _, ok := templates["mc-base"]; ok {
+		t.Fatal("abstract template loaded")
+	}
+	paper := templates["paper"]
+	if paper.Game != "minecraft" || paper.Label != "Paper" || paper.Extends != "" || paper.Mixins != nil {
+		t.Fatalf("paper = %+v", paper)
+	}
+	c := paper.Container
+	if c.Image != "itzg/minecraft-server:latest" || c.CPULimit != 2 || c.MemoryLimit != 8589934592 {
+		t.Fatalf("container = %+v", c)
+	}
+	wantEnv := map[string]string{"EULA": "TRUE", "TYPE": "PAPER", "INIT_MEMORY": "6G"}
+	if !reflect.DeepEqual(c.Environment, wantEnv) {
+		t.Fatalf("environment = %v", c.Environment)
+	}
+	if got := portNames(c.Ports); !reflect.DeepEqual(got, []string{"java", "rcon", "map"}) || c.Ports[0].Range != "26000-26099" {
+		t.Fatalf("ports = %+v", c.Ports)
+	}
+	if len(paper.Config.Engines) != 2 || paper.Config.Engines[0].Label != "Vanilla (Paper)" {
+		t.Fatalf("engines = %+v", paper.Config.Engines)
+	}
+	if raw["mc-base"].Container.Environment["TYPE"] != "VANILLA" {
+		t.Fatal("resolving modified the parent")
+	}
+}
+
+func TestResolveTemplatesErrors(t *testing.T) {
+	raw := parseTemplates(t,
+		"name: a\nextends: b\n",
+		"name: b\nmixins: [a]\n",
+		"name: orphan\nextends: missing\n",
+		"name: ok\ncontainer: {image: x}\n",
+	)
+	templates, errs := resolveTemplates(raw)
+	if len(templates) != 1 || templates["ok"].Container.Image != "x" {
+		t.Fatalf("templates = %v", templates)
+	}
+	if err := errs["a"]; err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("a: %v", err)
+	}
+	if err := errs["b"]; err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("b: %v", err)
+	}
+	if err := errs["orphan"]; err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("orphan: %v", err)
+	}
+}
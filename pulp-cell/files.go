@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp"
+)
+
+const renderedFilesPath = "rendered-files.json"
+
+// TemplateFile is a config file the cell renders into the server's volume.
+// Path is a container path inside one of the template's volumes; Content is
+// interpolated with the create variables, including the caller's settings as
+// settings.<key> and game_rules.<key> (see interpolate.go).
+type TemplateFile struct {
+	Path    string `yaml:"path" json:"path"`
+	Content string `yaml:"content" json:"content"`
+}
+
+// renderedFiles records what create rendered for one container, so
+// reconfigure can re-render with the changed settings and rewrite only the
+// files whose content changed.
+type renderedFiles struct {
+	ContainerID string                  `json:"container_id"`
+	Vars        map[string]string       `json:"vars"`
+	Files       map[string]renderedFile `json:"files"` // keyed by container path
+}
+
+type renderedFile struct {
+	HostPath string `json:"host_path"`
+	SHA256   string `json:"sha256"`
+}
+
+type fileRender struct {
+	path     string
+	hostPath string
+	content  []byte
+}
+
+// renderFiles interpolates every file and resolves where it lands on the
+// cell FS, failing before anything is written.
+func renderFiles(files []TemplateFile, volumes, vars map[string]string) ([]fileRender, error) {
+	renders := make([]fileRender, 0, len(files))
+	for _, file := range files {
+		hostPath, ok := volumeHostPath(volumes, file.Path)
+		if !ok {
+			return nil, fmt.Errorf("file %s: no volume holds it", file.Path)
+		}
+		content, err := interpolate(file.Content, vars)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", file.Path, err)
+		}
+		renders = append(renders, fileRender{path: file.Path, hostPath: hostPath, content: []byte(content)})
+	}
+	return renders, nil
+}
+
+// writeRenderedFiles writes each render whose content differs from prior and
+// returns the record of every render.
+func writeRenderedFiles(renders []fileRender, prior map[string]renderedFile) (map[string]renderedFile, error) {
+	written := make(map[string]renderedFile, len(renders))
+	for _, render := range renders {
+		digest := sha256.Sum256(render.content)
+		record := renderedFile{HostPath: render.hostPath, SHA256: hex.EncodeToString(digest[:])}
+		if prior[render.path] != record {
+			if i := strings.LastIndex(render.hostPath, "/"); i > 0 {
+				if err := pulp.FS.MkdirAll(render.hostPath[:i], 0o755); err != nil {
+					return nil, fmt.Errorf("file %s: %w", render.path, err)
+				}
+			}
+			if err := pulp.FS.Write(render.hostPath, render.content); err != nil {
+				return nil, fmt.Errorf("file %s: %w", render.path, err)
+			}
+		}
+		written[render.path] = record
+	}
+	return written, nil
+}
+
+// reconfigureVars applies a reconfigure env to the variables a server was
+// rendered with.
+func reconfigureVars(vars, env map[string]string) map[string]string {
+	out := make(map[string]string, len(vars)+len(env))
+	for k, v := range vars {
+		out[k] = v
+	}
+	for k, v := range settingVars(env) {
+		out[k] = v
+	}
+	return out
+}
+
+// rerenderFiles re-renders tmpl's files for containerID with env applied and
+// rewrites the ones that changed. A container with no record (created before
+// files were declared, or adopted) is left alone.
+func (p *provisioner) rerenderFiles(containerID string, tmpl Template, env map[string]string) error {
+	record, ok := p.rendered[containerID]
+	if !ok || len(tmpl.Files) == 0 {
+		return nil
+	}
+	volumes := make(map[string]string, len(record.Files))
+	for path, file := range record.Files {
+		volumes[file.HostPath] = path
+	}
+	vars := reconfigureVars(record.Vars, env)
+	var renders []fileRender
+	for _, file := range tmpl.Files {
+		hostPath, ok := volumeHostPath(volumes, file.Path)
+		if !ok {
+			log.Printf("[Files] %s: %s was not rendered at create, skipping", containerID, file.Path)
+			continue
+		}
+		content, err := interpolate(file.Content, vars)
+		if err != nil {
+			return fmt.Errorf("file %s: %w", file.Path, err)
+		}
+		renders = append(renders, fileRender{path: file.Path, hostPath: hostPath, content: []byte(content)})
+	}
+	written, err := writeRenderedFiles(renders, record.Files)
+	if err != nil {
+		return err
+	}
+	p.rendered[containerID] = renderedFiles{ContainerID: containerID, Vars: vars, Files: written}
+	p.persistRenderedFiles()
+	return nil
+}
+
+func (p *provisioner) recordRenderedFiles(containerID string, vars map[string]string, files map[string]renderedFile) {
+	if p.rendered == nil {
+		p.rendered = make(map[string]renderedFiles)
+	}
+	p.rendered[containerID] = renderedFiles{ContainerID: containerID, Vars: vars, Files: files}
+	p.persistRenderedFiles()
+}
+
+// forgetRenderedFiles drops a destroyed container's record.
+func (p *provisioner) forgetRenderedFiles(containerID string) {
+	if _, ok := p.rendered[containerID]; !ok {
+		return
+	}
+	delete(p.rendered, containerID)
+	p.persistRenderedFiles()
+}
+
+func (p *provisioner) loadRenderedFiles() error {
+	var records []renderedFiles
+	if found, err := loadCellState(renderedFilesPath, &records); err != nil || !found {
+		return err
+	}
+	if p.rendered == nil {
+		p.rendered = make(map[string]renderedFiles)
+	}
+	for _, record := range records {
+		if containerGone(record.ContainerID) {
+			continue
+		}
+		p.rendered[record.ContainerID] = record
+	}
+	return nil
+}
+
+func (p *provisioner) persistRenderedFiles() {
+	ids := make([]string, 0, len(p.rendered))
+	for id := range p.rendered {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	records := make([]renderedFiles, 0, len(ids))
+	for _, id := range ids {
+		records = append(records, p.rendered[id])
+	}
+	if err := storeCellState(renderedFilesPath, records); err != nil {
+		log.Printf("[Files] persist rendered files: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderFiles(t *testing.T) {
+	files := []TemplateFile{
+		{Path: "/data/config/bukkit.yml", Content: "settings:\n  allow-end: {{settings.allow_end}}\n"},
+		{Path: "/data/motd.txt", Content: "{{SERVER_ID}}"},
+	}
+	volumes := map[string]string{"/var/sessions/worlds/mc-1": "/data"}
+	vars := interpolationVars("paper", nil, map[string]string{"SERVER_ID": "mc-1"},
+		map[string]string{"FLEET_SETTING_allow_end": "false"}, "")
+
+	renders, err := renderFiles(files, volumes, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renders[0].hostPath != "var/sessions/worlds/mc-1/config/bukkit.yml" || !strings.Contains(string(renders[0].content), "allow-end: false") {
+		t.Fatalf("render = %+v", renders[0])
+	}
+
+	if _, err := renderFiles([]TemplateFile{{Path: "/etc/passwd", Content: "x"}}, volumes, vars); err == nil {
+		t.Fatal("rendered a file outside every volume")
+	}
+	if _, err := renderFiles([]TemplateFile{{Path: "/data/x", Content: "{{settings.missing}}"}}, volumes, vars); err == nil {
+		t.Fatal("rendered a file with an unknown variable")
+	}
+}
+
+func TestRerenderWritesOnlyChangedFiles(t *testing.T) {
+	files := []TemplateFile{
+		{Path: "/data/a.yml", Content: "pvp: {{settings.pvp}}"},
+		{Path: "/data/b.yml", Content: "id: {{SERVER_ID}}"},
+	}
+	volumes := map[string]string{"/worlds/mc-1": "/data"}
+	vars := reconfigureVars(map[string]string{"SERVER_ID": "mc-1", "settings.pvp": "true"}, nil)
+	renders, err := renderFiles(files, volumes, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every record matches, so nothing touches the FS.
+	prior := make(map[string]renderedFile)
+	for _, render := range renders {
+		digest := sha256.Sum256(render.content)
+		prior[render.path] = renderedFile{HostPath: render.hostPath, SHA256: hex.EncodeToString(digest[:])}
+	}
+	if record, err := writeRenderedFiles(renders, prior); err != nil || !reflect.DeepEqual(record, prior) {
+		t.Fatalf("record = %v, %v", record, err)
+	}
+
+	changed := reconfigureVars(vars, map[string]string{"FLEET_SETTING_pvp": "false"})
+	if changed["settings.pvp"] != "false" || changed["env.FLEET_SETTING_pvp"] != "false" || vars["settings.pvp"] != "true" {
+		t.Fatalf("vars = %v", changed)
+	}
+	rerendered, err := renderFiles(files, volumes, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rerendered[0].content) != "pvp: false" || string(rerendered[1].content) != string(renders[1].content) {
+		t.Fatalf("rerendered = %+v", rerendered)
+	}
+}
@@ -33,6 +33,25 @@ type fleetResources struct {
 	Memory int64   `json:"memory_limit"`
 }
 
+// fleetBeforeResume lets bootstrap re-admit a server whose capacity was released
+// while it was suspended. It defaults to a no-op so the lifecycle kernel stays
+// testable without the cell's allocators.
+var fleetBeforeResume = func(containerID string) error { return nil }
+
+// fleetReconfigureSettings lets bootstrap translate reconfigure env through
+// the server's template option targets. It returns the targeted edits to
+// apply, if any, and the rcon commands for every other key. The default is
+// the fixed allowlist alone.
+var fleetReconfigureSettings = func(containerID string, env map[string]string) (func() error, [][]string, error) {
+	commands, err := fleetReconfigureCommands(env)
+	return nil, commands, err
+}
+
+// fleetResolveContainer lets bootstrap map a server ID bound by a warm-pool
+// claim to its container, as the orchestration routes do. The default
+// treats every ID as a container.
+var fleetResolveContainer = func(id string) string { return id }
+
 type fleetLifecycleReceipt struct {
 	IdempotencyKey string          `json:"idempotency_key"`
 	EffectID       string          `json:"effect_id"`
@@ -57,6 +76,7 @@ func fleetLifecycleHandler(action string) pulpgin.HandlerFunc {
 			c.JSON(400, pulpgin.H{"error": "invalid container identity"})
 			return
 		}
+		containerID = fleetResolveContainer(containerID)
 		request, err := decodeFleetLifecycleRequest(c, action)
 		if err != nil {
 			c.JSON(400, pulpgin.H{"error": err.Error()})
@@ -106,7 +126,7 @@ func fleetLifecycleHandler(action string) pulpgin.HandlerFunc {
 			// The path may be a physical, scoped container ID. Resolve the
 			// caller's logical server ID through the host-owned scope and require
 			// it to be the exact same runtime object before any privileged work.
-			owned, err := getOwnedServer(request.ServerID)
+			owns, err := fleetServerOwnsContainer(request.ServerID, containerID, getOwnedServer)
 			if err != nil {
 				if isDockerNotFound(err) {
 					c.JSON(404, pulpgin.H{"error": "server not found"})
@@ -115,7 +135,7 @@ func fleetLifecycleHandler(action string) pulpgin.HandlerFunc {
 				c.JSON(500, pulpgin.H{"error": "scoped server lookup unavailable"})
 				return
 			}
-			if owned == nil || owned.ID != containerID {
+			if !owns {
 				c.JSON(409, pulpgin.H{"error": "server identity does not match container"})
 				return
 			}
@@ -149,6 +169,20 @@ func fleetLifecycleHandler(action string) pulpgin.HandlerFunc {
 	}
 }
 
+// fleetServerOwnsContainer reports whether serverID names containerID. A
+// claimed warm-pool standby keeps its standby Docker name, so the claim's
+// binding is accepted before the host-owned scope is asked.
+func fleetServerOwnsContainer(serverID, containerID string, getOwned func(string) (*docker.Server, error)) (bool, error) {
+	if fleetResolveContainer(serverID) == containerID && serverID != containerID {
+		return true, nil
+	}
+	owned, err := getOwned(serverID)
+	if err != nil {
+		return false, err
+	}
+	return owned != nil && owned.ID == containerID, nil
+}
+
 func fleetLifecycleStatus(action string) string {
 	switch action {
 	case "reconfigure":
@@ -215,10 +249,15 @@ func executeFleetLifecycle(action, containerID string, request fleetLifecycleReq
 		if request.Resources.CPU != 0 || request.Resources.Memory != 0 {
 			return errors.New("live resource reconfiguration is not supported by this runtime")
 		}
-		commands, err := fleetReconfigureCommands(request.Env)
+		apply, commands, err := fleetReconfigureSettings(containerID, request.Env)
 		if err != nil {
 			return err
 		}
+		if apply != nil {
+			if err := apply(); err != nil {
+				return fmt.Errorf("apply runtime configuration: %w", err)
+			}
+		}
 		for _, command := range commands {
 			if _, err := docker.Exec(containerID, command); err != nil {
 				return fmt.Errorf("apply runtime configuration: %w", err)
@@ -240,6 +279,9 @@ func executeFleetLifecycle(action, containerID string, request fleetLifecycleReq
 		if request.Resources.CPU != 0 || request.Resources.Memory != 0 || len(request.Env) != 0 {
 			return errors.New("resume does not accept resource or environment changes")
 		}
+		if err := fleetBeforeResume(containerID); err != nil {
+			return err
+		}
 		return docker.Restart(containerID)
 	case "restart":
 		if request.Resources.CPU != 0 || request.Resources.Memory != 0 || len(request.Env) != 0 {
@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
 )
 
 func TestFleetReconfigureCommandsAreTypedAllowlistedAndDeterministic(t *testing.T) {
@@ -100,3 +102,33 @@ func TestFleetLifecycleResourceChangesFailClosed(t *testing.T) {
 		t.Fatalf("resource reconfigure error = %v, want unsupported failure", err)
 	}
 }
+
+func TestFleetRestartAcceptsClaimedStandby(t *testing.T) {
+	prov := &provisioner{bindings: make(map[string]string)}
+	pool := newWarmPool(prov)
+	// What claim records once it hands standby c-standby to match-1; claim
+	// itself needs the docker capability.
+	pool.state.Claims["match-1"] = warmClaim{Template: "paper", ContainerID: "c-standby"}
+	prov.bindings["match-1"] = "c-standby"
+
+	defer func(prior func(string) string) { fleetResolveContainer = prior }(fleetResolveContainer)
+	fleetResolveContainer = prov.containerFor
+	containerID := fleetResolveContainer("match-1")
+	if containerID != "c-standby" {
+		t.Fatalf("claimed server resolved to %q", containerID)
+	}
+
+	// The standby keeps its standby name, so the host-owned scope has no
+	// server named match-1.
+	notOwned := func(string) (*docker.Server, error) { return nil, nil }
+	if owns, err := fleetServerOwnsContainer("match-1", containerID, notOwned); err != nil || !owns {
+		t.Fatalf("restart of claimed standby: owns=%v err=%v", owns, err)
+	}
+	if owns, _ := fleetServerOwnsContainer("match-1", "c-other", notOwned); owns {
+		t.Fatal("binding accepted for a different container")
+	}
+	owned := func(string) (*docker.Server, error) { return &docker.Server{ID: "c-plain"}, nil }
+	if owns, err := fleetServerOwnsContainer("plain-1", "c-plain", owned); err != nil || !owns {
+		t.Fatalf("unbound server: owns=%v err=%v", owns, err)
+	}
+}
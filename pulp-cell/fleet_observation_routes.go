@@ -358,7 +358,7 @@ func fleetObservationServer(c *pulpgin.Context) (*docker.Server, bool) {
 		c.JSON(400, pulpgin.H{"error": "invalid container identity"})
 		return nil, false
 	}
-	server, err := docker.Get(id)
+	server, err := docker.Get(fleetResolveContainer(id))
 	if err != nil {
 		c.JSON(404, pulpgin.H{"error": "server not found"})
 		return nil, false
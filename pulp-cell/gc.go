@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp"
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+)
+
+const (
+	gcStatePath         = "gc-state.json"
+	gcObserveInterval   = 10 * time.Minute
+	defaultGCMinAge     = 24 * time.Hour
+	defaultGCReceiptTTL = 7 * 24 * time.Hour
+)
+
+// GC candidate kinds.
+const (
+	gcContainer = "container"
+	gcWorld     = "world"
+	gcReceipt   = "receipt"
+	gcImage     = "image"
+)
+
+// gcCollector finds state the cell no longer needs: containers labelled with a
+// template that is no longer loaded, world directories with no container,
+// and fleet receipts for containers that no longer exist. Unused images and
+// unlabelled containers are listed as skipped but never collected: the
+// docker capability cannot list or remove images, and an unlabelled container
+// may not be Bananagine's.
+//
+// Neither docker.Server nor the cell FS carry a trustworthy creation time, so
+// age is measured from when the collector first saw a candidate orphaned. A
+// candidate is only collected once it has stayed orphaned for the configured
+// threshold; one that stops being orphaned (its template is reloaded, its
+// server recreated) starts over. First-seen times persist to gcStatePath and
+// accrue from the step loop, so a restart does not reset them.
+type gcCollector struct {
+	prov       *provisioner
+	worldsRoot string
+	minAge     time.Duration
+	receiptTTL time.Duration
+	allowlist  []string
+	// forget drops a removed container from the cell's other trackers.
+	forget      func(containerID string)
+	seen        map[string]int64 // candidate key -> unix time first seen orphaned
+	stored      []byte
+	nextObserve time.Time
+}
+
+// gcItem is one candidate. Target is the container ID or the FS path to
+// remove; Name is what the allowlist matches.
+type gcItem struct {
+	Kind       string `json:"kind"`
+	Target     string `json:"target"`
+	Name       string `json:"name"`
+	Reason     string `json:"reason"`
+	AgeSeconds int64  `json:"age_seconds"`
+	// skip reports a candidate that is never collected.
+	skip bool
+}
+
+type gcPlan struct {
+	GeneratedAt int64 `json:"generated_at"`
+	// Collect is what POST /admin/gc removes.
+	Collect []gcItem `json:"collect"`
+	// Pending candidates have not been orphaned long enough yet.
+	Pending []gcItem `json:"pending"`
+	// Skipped candidates are allowlisted or cannot be collected here.
+	Skipped []gcItem `json:"skipped"`
+}
+
+type gcFailure struct {
+	gcItem
+	Error string `json:"error"`
+}
+
+type gcResult struct {
+	gcPlan
+	Removed []gcItem    `json:"removed"`
+	Failed  []gcFailure `json:"failed"`
+}
+
+func newGCCollector(prov *provisioner, worldsRoot string, cfg appConfig, forget func(string)) *gcCollector {
+	return &gcCollector{
+		prov:       prov,
+		worldsRoot: worldsRoot,
+		minAge:     cfg.GCMinAge,
+		receiptTTL: cfg.GCReceiptTTL,
+		allowlist:  cfg.GCAllowlist,
+		forget:     forget,
+		seen:       make(map[string]int64),
+	}
+}
+
+func gcKey(item gcItem) string {
+	return item.Kind + ":" + item.Target
+}
+
+func (g *gcCollector) load() error {
+	_, err := loadCellState(gcStatePath, &g.seen)
+	if g.seen == nil {
+		g.seen = make(map[string]int64)
+	}
+	return err
+}
+
+func (g *gcCollector) persist() {
+	data, err := json.Marshal(g.seen)
+	if err != nil || bytes.Equal(data, g.stored) {
+		return
+	}
+	if err := storeCellState(gcStatePath, g.seen); err != nil {
+		log.Printf("[GC] persist state: %v", err)
+		return
+	}
+	g.stored = data
+}
+
+// step refreshes first-seen times once gcObserveInterval has elapsed, so
+// candidates age even when nobody asks for a plan.
+func (g *gcCollector) step(now time.Time) {
+	if now.Before(g.nextObserve) {
+		return
+	}
+	if _, err := g.plan(now); err != nil {
+		log.Printf("[GC] observe: %v", err)
+	}
+}
+
+func (g *gcCollector) plan(now time.Time) (gcPlan, error) {
+	g.nextObserve = now.Add(gcObserveInterval)
+	candidates, err := g.candidates()
+	if err != nil {
+		return gcPlan{}, err
+	}
+	plan := classifyGC(candidates, g.seen, now, g.thresholds(), g.allowlist)
+	g.persist()
+	return plan, nil
+}
+
+// apply removes everything the current plan collects.
+func (g *gcCollector) apply(now time.Time) (gcResult, error) {
+	plan, err := g.plan(now)
+	if err != nil {
+		return gcResult{}, err
+	}
+	result := gcResult{gcPlan: plan, Removed: []gcItem{}, Failed: []gcFailure{}}
+	for _, item := range plan.Collect {
+		if err := g.remove(item, now); err != nil {
+			log.Printf("[GC] remove %s %s: %v", item.Kind, item.Name, err)
+			result.Failed = append(result.Failed, gcFailure{gcItem: item, Error: err.Error()})
+			continue
+		}
+		delete(g.seen, gcKey(item))
+		result.Removed = append(result.Removed, item)
+		log.Printf("[GC] removed %s %s (%s)", item.Kind, item.Name, item.Reason)
+	}
+	g.persist()
+	return result, nil
+}
+
+func (g *gcCollector) remove(item gcItem, now time.Time) error {
+	switch item.Kind {
+	case gcContainer:
+		if err := docker.Destroy(item.Target); err != nil && !isDockerNotFound(err) {
+			return err
+		}
+		g.prov.release(item.Target)
+		g.forget(item.Target)
+		emitOrchestrationEvent(wireEvent{ContainerID: item.Target, Name: item.Name, Action: "gc-removed", Time: now.UnixNano()})
+		return nil
+	case gcWorld:
+		return pulp.FS.RemoveAll(item.Target)
+	case gcReceipt:
+		return pulp.FS.Remove(item.Target)
+	default:
+		return fmt.Errorf("%s cannot be collected", item.Kind)
+	}
+}
+
+func (g *gcCollector) thresholds() map[string]time.Duration {
+	return map[string]time.Duration{
+		gcContainer: g.minAge,
+		gcWorld:     g.minAge,
+		gcReceipt:   g.receiptTTL,
+	}
+}
+
+// candidates lists everything currently orphaned, ignoring age and allowlist.
+func (g *gcCollector) candidates() ([]gcItem, error) {
+	servers, err := docker.List(nil)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+	var items []gcItem
+	live := make(map[string]struct{}, len(servers)+len(g.prov.bindings))
+	for serverID := range g.prov.bindings {
+		live[serverID] = struct{}{}
+	}
+	for _, server := range servers {
+		server = g.prov.labeled(server)
+		name := strings.TrimPrefix(server.Name, "/")
+		live[name] = struct{}{}
+		if serverID := server.Labels[serverIDLabel]; serverID != "" {
+			live[serverID] = struct{}{}
+		}
+		// Unlabelled containers may not be ours at all, so only a container
+		// whose label names a template is ever collected. One that matches
+		// no loaded template prefix either is reported for an operator.
+		template := server.Labels[templateLabel]
+		if template == "" {
+			if !g.prov.manages(server) {
+				items = append(items, gcItem{
+					Kind: gcContainer, Target: server.ID, Name: name, skip: true,
+					Reason: "no bananagine.template label and no loaded template prefix",
+				})
+			}
+			continue
+		}
+		if _, loaded := g.prov.templates[template]; !loaded {
+			items = append(items, gcItem{
+				Kind: gcContainer, Target: server.ID, Name: name,
+				Reason: fmt.Sprintf("template %q is not loaded", template),
+			})
+		}
+	}
+	items = append(items, g.danglingWorlds(live)...)
+	for _, dir := range []string{fleetReceiptDir, fleetExecV2ReceiptDir} {
+		items = append(items, staleReceipts(dir)...)
+	}
+	items = append(items, gcItem{
+		Kind: gcImage, Name: "*",
+		Reason: "unused images are not collected: the docker capability cannot list or remove images",
+	})
+	return items, nil
+}
+
+// danglingWorlds lists world directories no server owns. A world is named
+// after its server ID, which is the Docker name, the server-id label, or a
+// warm-pool binding. docker.List can omit stopped containers, so a world
+// missing from live is only dangling once its server ID resolves to nothing.
+func (g *gcCollector) danglingWorlds(live map[string]struct{}) []gcItem {
+	entries, err := pulp.FS.List(g.worldsRoot)
+	if err != nil {
+		return nil
+	}
+	var items []gcItem
+	for _, entry := range entries {
+		if !entry.IsDir {
+			continue
+		}
+		if _, ok := live[entry.Name]; ok {
+			continue
+		}
+		if _, found, err := existingServerForRequestedID(entry.Name, docker.Get); found || err != nil {
+			continue
+		}
+		items = append(items, gcItem{
+			Kind: gcWorld, Target: g.worldsRoot + "/" + entry.Name, Name: entry.Name,
+			Reason: "no container named " + entry.Name,
+		})
+	}
+	return items
+}
+
+// staleReceipts lists fleet receipts whose container no longer exists. A
+// receipt only answers retries against its container, so once that is gone
+// the receipt can only replay a response for a server that no longer exists.
+func staleReceipts(dir string) []gcItem {
+	entries, err := pulp.FS.List(dir)
+	if err != nil {
+		return nil
+	}
+	gone := make(map[string]bool)
+	var items []gcItem
+	for _, entry := range entries {
+		if entry.IsDir || !strings.HasSuffix(entry.Name, ".json") {
+			continue
+		}
+		target := dir + "/" + entry.Name
+		wire, err := pulp.FS.Read(target)
+		if err != nil {
+			continue
+		}
+		var receipt struct {
+			ContainerID string `json:"container_id"`
+		}
+		if json.Unmarshal(wire, &receipt) != nil || receipt.ContainerID == "" {
+			continue
+		}
+		if _, checked := gone[receipt.ContainerID]; !checked {
+			gone[receipt.ContainerID] = containerGone(receipt.ContainerID)
+		}
+		if gone[receipt.ContainerID] {
+			items = append(items, gcItem{
+				Kind: gcReceipt, Target: target, Name: entry.Name,
+				Reason: "container " + receipt.ContainerID + " no longer exists",
+			})
+		}
+	}
+	return items
+}
+
+// classifyGC splits candidates into collect, pending and skipped, recording
+// first-seen times in seen and dropping entries that are no longer orphaned.
+// A kind missing from thresholds, or an item marked skip, cannot be collected
+// here.
+func classifyGC(candidates []gcItem, seen map[string]int64, now time.Time, thresholds map[string]time.Duration, allowlist []string) gcPlan {
+	plan := gcPlan{GeneratedAt: now.Unix(), Collect: []gcItem{}, Pending: []gcItem{}, Skipped: []gcItem{}}
+	current := make(map[string]struct{}, len(candidates))
+	for _, item := range candidates {
+		threshold, collectable := thresholds[item.Kind]
+		if !collectable || item.skip {
+			plan.Skipped = append(plan.Skipped, item)
+			continue
+		}
+		key := gcKey(item)
+		current[key] = struct{}{}
+		if _, ok := seen[key]; !ok {
+			seen[key] = now.Unix()
+		}
+		item.AgeSeconds = now.Unix() - seen[key]
+		switch {
+		case gcAllowlisted(item.Name, allowlist):
+			item.Reason = "allowlisted"
+			plan.Skipped = append(plan.Skipped, item)
+		case time.Duration(item.AgeSeconds)*time.Second < threshold:
+			plan.Pending = append(plan.Pending, item)
+		default:
+			plan.Collect = append(plan.Collect, item)
+		}
+	}
+	for key := range seen {
+		if _, ok := current[key]; !ok {
+			delete(seen, key)
+		}
+	}
+	for _, items := range [][]gcItem{plan.Collect, plan.Pending, plan.Skipped} {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].Kind != items[j].Kind {
+				return items[i].Kind < items[j].Kind
+			}
+			return items[i].Target < items[j].Target
+		})
+	}
+	return plan
+}
+
+// gcAllowlisted matches name against the gc_allowlist path.Match patterns.
+func gcAllowlisted(name string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func registerGCRoutes(group *pulpgin.RouterGroup, gc *gcCollector) {
+	group.GET("/gc/plan", func(c *pulpgin.Context) {
+		plan, err := gc.plan(time.Now())
+		if err != nil {
+			c.JSON(500, pulpgin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, plan)
+	})
+	group.POST("/gc", func(c *pulpgin.Context) {
+		result, err := gc.apply(time.Now())
+		if err != nil {
+			c.JSON(500, pulpgin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, result)
+	})
+}
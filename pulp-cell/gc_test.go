@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyGCAgesCandidatesFromFirstSeen(t *testing.T) {
+	thresholds := map[string]time.Duration{
+		gcContainer: 24 * time.Hour,
+		gcWorld:     24 * time.Hour,
+		gcReceipt:   time.Hour,
+	}
+	start := time.Unix(1_000_000, 0)
+	seen := map[string]int64{"world:worlds/gone": 1}
+	candidates := []gcItem{
+		{Kind: gcContainer, Target: "c1", Name: "old-1"},
+		{Kind: gcWorld, Target: "worlds/keep-me", Name: "keep-me"},
+		{Kind: gcReceipt, Target: "receipts/a.json", Name: "a.json"},
+		{Kind: gcContainer, Target: "c9", Name: "legacy", skip: true},
+		{Kind: gcImage, Name: "*"},
+	}
+
+	plan := classifyGC(candidates, seen, start, thresholds, []string{"keep-*"})
+	if len(plan.Collect) != 0 || len(plan.Pending) != 2 || len(plan.Skipped) != 3 {
+		t.Fatalf("first pass = %+v", plan)
+	}
+	if _, ok := seen["world:worlds/gone"]; ok {
+		t.Fatal("seen kept an entry that is no longer a candidate")
+	}
+	if _, ok := seen["container:c9"]; ok {
+		t.Fatal("a skipped container was aged")
+	}
+	if seen["container:c1"] != start.Unix() {
+		t.Fatalf("first seen = %d, want %d", seen["container:c1"], start.Unix())
+	}
+
+	plan = classifyGC(candidates, seen, start.Add(2*time.Hour), thresholds, []string{"keep-*"})
+	if len(plan.Collect) != 1 || plan.Collect[0].Kind != gcReceipt || plan.Collect[0].AgeSeconds != 7200 {
+		t.Fatalf("receipt TTL elapsed, collect = %+v", plan.Collect)
+	}
+	if len(plan.Pending) != 1 || plan.Pending[0].Target != "c1" {
+		t.Fatalf("container still young, pending = %+v", plan.Pending)
+	}
+
+	plan = classifyGC(candidates, seen, start.Add(25*time.Hour), thresholds, []string{"keep-*"})
+	if len(plan.Collect) != 2 || plan.Collect[0].Kind != gcContainer {
+		t.Fatalf("collect = %+v", plan.Collect)
+	}
+	for _, item := range plan.Skipped {
+		if item.Kind == gcWorld && item.Reason != "allowlisted" {
+			t.Fatalf("allowlisted world skipped for %q", item.Reason)
+		}
+	}
+}
+
+func TestGCAllowlisted(t *testing.T) {
+	allowlist := []string{"keep-*", "lobby"}
+	for name, want := range map[string]bool{
+		"keep-1":  true,
+		"lobby":   true,
+		"lobby-2": false,
+		"mc-1":    false,
+	} {
+		if got := gcAllowlisted(name, allowlist); got != want {
+			t.Errorf("gcAllowlisted(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+)
+
+const (
+	idleStatePath     = "idle-servers.json"
+	idleCheckInterval = time.Minute
+)
+
+// IdlePolicy suspends a server after it has reported zero online players for
+// SuspendAfterMinutes. Zero disables the policy.
+type IdlePolicy struct {
+	SuspendAfterMinutes int `yaml:"suspend_after_minutes" json:"suspend_after_minutes"`
+}
+
+// idleTracker polls the player count of every server with an idle policy and
+// suspends those that stayed empty for their threshold, releasing their CPU and
+// memory budget. wake re-admits a suspended server against capacity before it
+// is started again.
+type idleTracker struct {
+	prov      *provisioner
+	servers   map[string]idleServer // container ID -> tracked server
+	nextCheck time.Time
+}
+
+type idleServer struct {
+	ServerID            string `json:"server_id"`
+	SuspendAfterMinutes int    `json:"suspend_after_minutes"`
+	// IdleSince is the unix second the server was first seen empty; zero while
+	// players are online or the count is unknown.
+	IdleSince int64 `json:"idle_since,omitempty"`
+	Suspended bool  `json:"suspended,omitempty"`
+}
+
+func newIdleTracker(prov *provisioner) *idleTracker {
+	return &idleTracker{prov: prov, servers: make(map[string]idleServer)}
+}
+
+// load restores tracked servers. Startup reconciliation counts every managed
+// container toward capacity, so suspended servers give that budget back here.
+func (t *idleTracker) load() error {
+	var servers map[string]idleServer
+	if found, err := loadCellState(idleStatePath, &servers); err != nil || !found {
+		return err
+	}
+	for id, server := range servers {
+		if containerGone(id) {
+			continue
+		}
+		t.servers[id] = server
+		if server.Suspended {
+			t.prov.capacity.release(id)
+		}
+	}
+	return t.persist()
+}
+
+func (t *idleTracker) persist() error {
+	return storeCellState(idleStatePath, t.servers)
+}
+
+// track applies an idle policy to a newly created server. An already tracked
+// server keeps its state so an idempotent create retry does not reset it.
+func (t *idleTracker) track(containerID, serverID string, suspendAfterMinutes int) {
+	if suspendAfterMinutes <= 0 {
+		return
+	}
+	if _, tracked := t.servers[containerID]; tracked {
+		return
+	}
+	t.servers[containerID] = idleServer{ServerID: serverID, SuspendAfterMinutes: suspendAfterMinutes}
+	if err := t.persist(); err != nil {
+		log.Printf("[Idle] persist state: %v", err)
+	}
+}
+
+func (t *idleTracker) suspended(containerID string) bool {
+	return t.servers[containerID].Suspended
+}
+
+func (t *idleTracker) forget(containerID string) {
+	if _, tracked := t.servers[containerID]; !tracked {
+		return
+	}
+	delete(t.servers, containerID)
+	if err := t.persist(); err != nil {
+		log.Printf("[Idle] persist state: %v", err)
+	}
+}
+
+// check runs from the step loop, throttled to idleCheckInterval, and suspends
+// every running tracked server that has been empty past its threshold.
+func (t *idleTracker) check(now time.Time) {
+	if now.Before(t.nextCheck) {
+		return
+	}
+	t.nextCheck = now.Add(idleCheckInterval)
+	ids := make([]string, 0, len(t.servers))
+	for id, server := range t.servers {
+		if !server.Suspended {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	changed := false
+	for _, id := range ids {
+		server := t.servers[id]
+		output, err := docker.Exec(id, []string{"rcon", "list"})
+		var players []string
+		if err == nil {
+			players, err = parseFleetPlayers(output)
+		}
+		next := observeIdle(server, err == nil, len(players), now.Unix())
+		if next != server {
+			t.servers[id] = next
+			changed = true
+		}
+		if !idleExpired(next, now.Unix()) {
+			continue
+		}
+		if err := executeFleetLifecycle("suspend", id, fleetLifecycleRequest{}); err != nil {
+			log.Printf("[Idle] suspend %s: %v", next.ServerID, err)
+			continue
+		}
+		t.prov.capacity.release(id)
+		next.Suspended = true
+		next.IdleSince = 0
+		t.servers[id] = next
+		changed = true
+		log.Printf("[Idle] %s suspended after %d idle minutes", next.ServerID, next.SuspendAfterMinutes)
+		emitOrchestrationEvent(wireEvent{ContainerID: id, Name: next.ServerID, Action: "idle-suspended", Time: now.UnixNano()})
+	}
+	if changed {
+		if err := t.persist(); err != nil {
+			log.Printf("[Idle] persist state: %v", err)
+		}
+	}
+}
+
+// readmit takes capacity back for an idle-suspended server before it starts
+// again. Servers that were not suspended for idleness pass through untouched.
+// Failures are *provisionError so routes answer with the create path's status.
+func (t *idleTracker) readmit(containerID string) error {
+	server, tracked := t.servers[containerID]
+	if !tracked || !server.Suspended {
+		return nil
+	}
+	runtime, err := docker.Get(containerID)
+	if err != nil {
+		if isDockerNotFound(err) {
+			return &provisionError{status: 404, message: "server not found"}
+		}
+		return provisionFailure(500, err)
+	}
+	if err := t.prov.capacity.tryAllocate(containerID, runtime.CPULimit, runtime.MemoryLimit); err != nil {
+		return provisionFailure(503, err)
+	}
+	server.Suspended = false
+	t.servers[containerID] = server
+	if err := t.persist(); err != nil {
+		log.Printf("[Idle] persist state: %v", err)
+	}
+	return nil
+}
+
+// wake is the on-demand resume hook: it re-admits and restarts an
+// idle-suspended server. Waking a server that is not suspended is a no-op.
+func (t *idleTracker) wake(containerID string) (bool, error) {
+	server, tracked := t.servers[containerID]
+	if !tracked || !server.Suspended {
+		return false, nil
+	}
+	if err := t.readmit(containerID); err != nil {
+		return false, err
+	}
+	if err := docker.Restart(containerID); err != nil {
+		t.prov.capacity.release(containerID)
+		server.Suspended = true
+		t.servers[containerID] = server
+		if persistErr := t.persist(); persistErr != nil {
+			log.Printf("[Idle] persist state: %v", persistErr)
+		}
+		return false, provisionFailure(500, err)
+	}
+	emitOrchestrationEvent(wireEvent{ContainerID: containerID, Name: server.ServerID, Action: "idle-resumed", Time: time.Now().UnixNano()})
+	return true, nil
+}
+
+// observeIdle folds one player-count probe into a server's idle clock. An
+// unknown count (probe failed, server still booting) resets the clock rather
+// than risk suspending a server nobody could see.
+func observeIdle(server idleServer, known bool, players int, nowUnix int64) idleServer {
+	switch {
+	case !known || players > 0:
+		server.IdleSince = 0
+	case server.IdleSince == 0:
+		server.IdleSince = nowUnix
+	}
+	return server
+}
+
+func idleExpired(server idleServer, nowUnix int64) bool {
+	return !server.Suspended &&
+		server.SuspendAfterMinutes > 0 &&
+		server.IdleSince > 0 &&
+		nowUnix-server.IdleSince >= int64(server.SuspendAfterMinutes)*60
+}
+
+// idleSuspendMinutes resolves a server's threshold: a positive per-server value
+// wins, a negative one opts the server out, and zero inherits the template's.
+func idleSuspendMinutes(tmpl Template, requested int) int {
+	switch {
+	case requested > 0:
+		return requested
+	case requested < 0:
+		return 0
+	case tmpl.Idle != nil:
+		return tmpl.Idle.SuspendAfterMinutes
+	default:
+		return 0
+	}
+}
+
+func registerIdleRoutes(group *pulpgin.RouterGroup, idle *idleTracker) {
+	group.POST("/servers/:id/wake", func(c *pulpgin.Context) {
+		id := idle.prov.containerFor(strings.TrimSpace(c.Param("id")))
+		woken, err := idle.wake(id)
+		if err != nil {
+			var perr *provisionError
+			if errors.As(err, &perr) {
+				c.JSON(perr.status, pulpgin.H{"error": perr.message})
+				return
+			}
+			c.JSON(500, pulpgin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, pulpgin.H{"id": id, "woken": woken})
+	})
+}
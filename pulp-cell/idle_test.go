@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestObserveIdle(t *testing.T) {
+	tests := []struct {
+		name    string
+		since   int64
+		known   bool
+		players int
+		want    int64
+	}{
+		{name: "first empty probe starts clock", known: true, want: 100},
+		{name: "empty probe keeps clock", since: 40, known: true, want: 40},
+		{name: "players reset clock", since: 40, known: true, players: 2, want: 0},
+		{name: "unknown count resets clock", since: 40, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := observeIdle(idleServer{IdleSince: tt.since}, tt.known, tt.players, 100)
+			if got.IdleSince != tt.want {
+				t.Fatalf("IdleSince = %d, want %d", got.IdleSince, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdleExpired(t *testing.T) {
+	tests := []struct {
+		name   string
+		server idleServer
+		want   bool
+	}{
+		{name: "threshold reached", server: idleServer{SuspendAfterMinutes: 5, IdleSince: 700}, want: true},
+		{name: "below threshold", server: idleServer{SuspendAfterMinutes: 5, IdleSince: 701}},
+		{name: "not idle", server: idleServer{SuspendAfterMinutes: 5}},
+		{name: "already suspended", server: idleServer{SuspendAfterMinutes: 5, IdleSince: 1, Suspended: true}},
+		{name: "no policy", server: idleServer{IdleSince: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idleExpired(tt.server, 1000); got != tt.want {
+				t.Fatalf("idleExpired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdleSuspendMinutes(t *testing.T) {
+	withPolicy := Template{Idle: &IdlePolicy{SuspendAfterMinutes: 15}}
+	tests := []struct {
+		name      string
+		tmpl      Template
+		requested int
+		want      int
+	}{
+		{name: "inherits template", tmpl: withPolicy, want: 15},
+		{name: "request overrides", tmpl: withPolicy, requested: 3, want: 3},
+		{name: "negative opts out", tmpl: withPolicy, requested: -1, want: 0},
+		{name: "no policy", tmpl: Template{}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idleSuspendMinutes(tt.tmpl, tt.requested); got != tt.want {
+				t.Fatalf("idleSuspendMinutes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
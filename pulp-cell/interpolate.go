@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Template fields are interpolated with {{NAME}} references once a server's
+// ID, host and ports are known: the image, environment values (including the
+// template's `server` map), volume host and container paths, and the
+// pre_start hook URL. Spaces inside the braces are ignored, `\{{` is a literal
+// `{{`, and a malformed or unknown reference fails the create. Values are
+// never re-expanded, so a caller-supplied value cannot inject further
+// references. They are substituted verbatim, except in the hook URL, where
+// interpolateURL escapes them so a value cannot change the URL's structure.
+//
+// Names are:
+//
+//	SERVER_ID, TEMPLATE, SERVER_HOST, SERVER_PORT  the injected values
+//	PORT_<NAME>                                    a named port's allocation
+//	env.<KEY>                                      the caller's env[KEY]
+//	settings.<key>, game_rules.<key>               the caller's FLEET_SETTING_<key> and FLEET_GAMERULE_<key>
+//	node.external_host                             the external_host config key, when set
+
+// interpolationVars collects the names above. env is the container
+// environment after the injected values were set; callerEnv is the create
+// request's env.
+func interpolationVars(template string, ports []PortSpec, env, callerEnv map[string]string, externalHost string) map[string]string {
+	vars := map[string]string{
+		"SERVER_ID":   env["SERVER_ID"],
+		"TEMPLATE":    template,
+		"SERVER_HOST": env["SERVER_HOST"],
+		"SERVER_PORT": env["SERVER_PORT"],
+	}
+	for _, p := range ports {
+		if p.Name != "" {
+			key := "PORT_" + strings.ToUpper(p.Name)
+			vars[key] = env[key]
+		}
+	}
+	for k, v := range settingVars(callerEnv) {
+		vars[k] = v
+	}
+	if externalHost != "" {
+		vars["node.external_host"] = externalHost
+	}
+	return vars
+}
+
+// settingVars maps a caller env to its env.<KEY> variables, plus
+// settings.<key> and game_rules.<key> for FLEET_SETTING_* and FLEET_GAMERULE_*
+// keys.
+func settingVars(env map[string]string) map[string]string {
+	vars := make(map[string]string, len(env))
+	for k, v := range env {
+		vars["env."+k] = v
+		switch {
+		case strings.HasPrefix(k, settingEnvPrefix):
+			vars["settings."+strings.TrimPrefix(k, settingEnvPrefix)] = v
+		case strings.HasPrefix(k, gameRuleEnvPrefix):
+			vars["game_rules."+strings.TrimPrefix(k, gameRuleEnvPrefix)] = v
+		}
+	}
+	return vars
+}
+
+// interpolateContainer expands references in every interpolated container
+// field, naming the field in the error.
+func interpolateContainer(container *ContainerSpec, vars map[string]string) error {
+	image, err := interpolate(container.Image, vars)
+	if err != nil {
+		return fmt.Errorf("image: %w", err)
+	}
+	container.Image = image
+
+	keys := make([]string, 0, len(container.Environment))
+	for k := range container.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value, err := interpolate(container.Environment[k], vars)
+		if err != nil {
+			return fmt.Errorf("environment %s: %w", k, err)
+		}
+		container.Environment[k] = value
+	}
+
+	if len(container.Volumes) > 0 {
+		hostPaths := make([]string, 0, len(container.Volumes))
+		for hostPath := range container.Volumes {
+			hostPaths = append(hostPaths, hostPath)
+		}
+		sort.Strings(hostPaths)
+		volumes := make(map[string]string, len(container.Volumes))
+		for _, hostPath := range hostPaths {
+			host, err := interpolate(hostPath, vars)
+			if err != nil {
+				return fmt.Errorf("volume %s: %w", hostPath, err)
+			}
+			if err := checkVolumeHostPath(hostPath, host); err != nil {
+				return fmt.Errorf("volume %s: %w", hostPath, err)
+			}
+			if _, taken := volumes[host]; taken {
+				return fmt.Errorf("volume %s: host path %q is already mounted", hostPath, host)
+			}
+			target, err := interpolate(container.Volumes[hostPath], vars)
+			if err != nil {
+				return fmt.Errorf("volume %s: %w", hostPath, err)
+			}
+			volumes[host] = target
+		}
+		container.Volumes = volumes
+	}
+	return nil
+}
+
+// checkVolumeHostPath rejects an interpolated host path that can leave the
+// directory the template meant: one with a ".." element, or an absolute one
+// the template wrote as relative. Variable values are caller controlled, and
+// Docker mounts whatever host path it is given.
+func checkVolumeHostPath(template, host string) error {
+	for _, element := range strings.Split(host, "/") {
+		if element == ".." {
+			return fmt.Errorf("host path %q leaves its directory", host)
+		}
+	}
+	if strings.HasPrefix(host, "/") && !strings.HasPrefix(template, "/") {
+		return fmt.Errorf("host path %q is absolute", host)
+	}
+	return nil
+}
+
+// interpolate expands {{NAME}} references in s; see the top of this file.
+func interpolate(s string, vars map[string]string) (string, error) {
+	return expand(s, vars, func(value string) string { return value })
+}
+
+// interpolateURL expands references in a URL, escaping each value as a path
+// segment. A space becomes %20 and `/`, `?`, `#`, `@` and `:` are escaped, so
+// a value stays inside the component it was written into.
+func interpolateURL(s string, vars map[string]string) (string, error) {
+	return expand(s, vars, func(value string) string {
+		return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+	})
+}
+
+func expand(s string, vars map[string]string, escape func(string) string) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], `\{{`):
+			b.WriteString("{{")
+			i += 3
+		case strings.HasPrefix(s[i:], "{{"):
+			end := strings.Index(s[i+2:], "}}")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference in %q", s)
+			}
+			name := strings.TrimSpace(s[i+2 : i+2+end])
+			if !validVariableName(name) {
+				return "", fmt.Errorf("invalid reference {{%s}}", s[i+2:i+2+end])
+			}
+			value, ok := vars[name]
+			if !ok {
+				return "", fmt.Errorf("unknown variable %s", name)
+			}
+			b.WriteString(escape(value))
+			i += 2 + end + 2
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// validVariableName accepts identifiers, optionally namespaced with one dot
+// (env.KEY, node.external_host).
+func validVariableName(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+		for i, r := range part {
+			switch {
+			case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+			case r >= '0' && r <= '9' && i > 0:
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
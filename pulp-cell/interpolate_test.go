@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	vars := interpolationVars("paper",
+		[]PortSpec{{Name: "java"}, {Name: "rcon"}},
+		map[string]string{"SERVER_ID": "mc-1", "SERVER_HOST": "0.0.0.0", "SERVER_PORT": "6000", "PORT_JAVA": "6000", "PORT_RCON": "6001"},
+		map[string]string{"VERSION": "1.21", "MOTD": "{{SERVER_ID}}"},
+		"play.example.com",
+	)
+	for in, want := range map[string]string{
+		"plain":                                 "plain",
+		"/worlds/{{SERVER_ID}}":                 "/worlds/mc-1",
+		"{{ TEMPLATE }}:{{PORT_RCON}}":          "paper:6001",
+		"itzg/minecraft-server:{{env.VERSION}}": "itzg/minecraft-server:1.21",
+		"https://{{node.external_host}}/hook":   "https://play.example.com/hook",
+		`\{{SERVER_ID}}`:                        "{{SERVER_ID}}",
+		"motd={{env.MOTD}}":                     "motd={{SERVER_ID}}",
+	} {
+		got, err := interpolate(in, vars)
+		if err != nil || got != want {
+			t.Errorf("interpolate(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for in, want := range map[string]string{
+		"{{PORT_QUERY}}":  "unknown variable PORT_QUERY",
+		"{{env.MISSING}}": "unknown variable env.MISSING",
+		"{{SERVER_ID":     "unterminated",
+		"{{a.b.c}}":       "invalid reference",
+		"{{1ABC}}":        "invalid reference",
+	} {
+		if _, err := interpolate(in, vars); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("interpolate(%q) error = %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestInterpolateURLEscapesValues(t *testing.T) {
+	vars := map[string]string{"SERVER_ID": "mc-1", "env.MAP": "a/b?admin=1#x", "env.USER": "me@evil:80", "env.NAME": "big world"}
+	got, err := interpolateURL("http://hooks.internal/prestart/{{SERVER_ID}}?map={{env.MAP}}&user={{env.USER}}&name={{env.NAME}}", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://hooks.internal/prestart/mc-1?map=a%2Fb%3Fadmin%3D1%23x&user=me%40evil%3A80&name=big%20world"; got != want {
+		t.Fatalf("interpolateURL = %q, want %q", got, want)
+	}
+}
+
+func TestInterpolateContainer(t *testing.T) {
+	vars := map[string]string{"SERVER_ID": "mc-1", "env.TYPE": "PAPER"}
+	container := ContainerSpec{
+		Image:       "img:{{env.TYPE}}",
+		Environment: map[string]string{"TYPE": "{{env.TYPE}}", "LEVEL": "world-{{SERVER_ID}}"},
+		Volumes:     map[string]string{"/worlds/{{SERVER_ID}}": "/data/{{SERVER_ID}}"},
+	}
+	if err := interpolateContainer(&container, vars); err != nil {
+		t.Fatal(err)
+	}
+	if container.Image != "img:PAPER" || container.Environment["LEVEL"] != "world-mc-1" {
+		t.Fatalf("container = %+v", container)
+	}
+	if want := map[string]string{"/worlds/mc-1": "/data/mc-1"}; !reflect.DeepEqual(container.Volumes, want) {
+		t.Fatalf("volumes = %v", container.Volumes)
+	}
+
+	container.Environment["BAD"] = "{{NOPE}}"
+	if err := interpolateContainer(&container, vars); err == nil || !strings.Contains(err.Error(), "environment BAD") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestInterpolateContainerRejectsEscapingVolumes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		volumes map[string]string
+		env     string
+		want    string
+	}{
+		"parent element": {map[string]string{"/worlds/{{env.DIR}}": "/data"}, "../../etc", "leaves its directory"},
+		"absolute":       {map[string]string{"{{env.DIR}}/world": "/data"}, "/etc", "is absolute"},
+		"collision": {
+			map[string]string{"/worlds/{{env.DIR}}": "/data", "/worlds/shared": "/extra"},
+			"shared", "already mounted",
+		},
+	} {
+		container := ContainerSpec{Volumes: tc.volumes}
+		err := interpolateContainer(&container, map[string]string{"env.DIR": tc.env})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", name, err, tc.want)
+		}
+	}
+
+	container := ContainerSpec{Volumes: map[string]string{"worlds/{{env.DIR}}": "/data"}}
+	if err := interpolateContainer(&container, map[string]string{"env.DIR": "lobby..v2"}); err != nil {
+		t.Fatalf("dotted name rejected: %v", err)
+	}
+}
@@ -1,28 +1,159 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"net"
+	"sort"
+	"strings"
+	"time"
 )
 
 // Single-threaded WASM — no mutex needed. See capacity.go for details.
 type ipPool struct {
-	start     net.IP
+	start     net.IP // 4 bytes for IPv4, 16 for IPv6; end matches
 	end       net.IP
 	allocated map[string]string // IP -> server ID
+	since     map[string]int64  // IP -> unix second it was leased
 }
 
-func newIPPool(start, end string) *ipPool {
-	startIP := net.ParseIP(start).To4()
-	endIP := net.ParseIP(end).To4()
+// newIPPool builds a pool over an inclusive start/end range of one family.
+func newIPPool(start, end string) (*ipPool, error) {
+	startIP, endIP := parsePoolIP(start), parsePoolIP(end)
 	if startIP == nil || endIP == nil {
-		panic(fmt.Sprintf("invalid IP pool range: %s - %s", start, end))
+		return nil, fmt.Errorf("invalid IP pool range: %s - %s", start, end)
+	}
+	if len(startIP) != len(endIP) {
+		return nil, fmt.Errorf("IP pool range %s - %s mixes IPv4 and IPv6", start, end)
+	}
+	if bytes.Compare(startIP, endIP) > 0 {
+		return nil, fmt.Errorf("IP pool range %s - %s ends before it starts", start, end)
 	}
 	return &ipPool{
 		start:     startIP,
 		end:       endIP,
 		allocated: make(map[string]string),
+		since:     make(map[string]int64),
+	}, nil
+}
+
+// newIPPoolCIDR builds a pool over a subnet, skipping the network address and
+// the first host, which Docker gives the network's gateway. An IPv4 pool also
+// skips the broadcast address.
+func newIPPoolCIDR(cidr string) (*ipPool, error) {
+	_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP pool CIDR %q: %w", cidr, err)
+	}
+	first := normalizeIP(network.IP)
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+	start := append(net.IP(nil), first...)
+	incIP(start)
+	incIP(start)
+	if len(last) == net.IPv4len {
+		decIP(last)
+	}
+	if bytes.Compare(start, last) > 0 || bytes.Compare(start, first) <= 0 {
+		return nil, fmt.Errorf("IP pool CIDR %s has no assignable addresses", cidr)
+	}
+	return &ipPool{
+		start:     start,
+		end:       last,
+		allocated: make(map[string]string),
+		since:     make(map[string]int64),
+	}, nil
+}
+
+// ipPoolFromConfig builds a pool from a CIDR, else a start/end range. It
+// returns nil when neither is set.
+func ipPoolFromConfig(cidr, start, end string) (*ipPool, error) {
+	switch {
+	case cidr != "":
+		return newIPPoolCIDR(cidr)
+	case start != "" || end != "":
+		return newIPPool(start, end)
+	default:
+		return nil, nil
+	}
+}
+
+// configuredNetworkPool builds the cell's default overlay pool from the
+// ip_pool_* and ip6_pool_* keys. Setting both makes the cell dual-stack.
+func configuredNetworkPool(cfg appConfig) (*networkPool, error) {
+	primary, err := ipPoolFromConfig(cfg.IPCIDR, cfg.IPStart, cfg.IPEnd)
+	if err != nil {
+		return nil, err
+	}
+	v6, err := ipPoolFromConfig(cfg.IP6CIDR, cfg.IP6Start, cfg.IP6End)
+	if err != nil {
+		return nil, err
+	}
+	var pools []*ipPool
+	if primary != nil {
+		pools = append(pools, primary)
+	}
+	if v6 != nil {
+		if v6.family() != "ipv6" {
+			return nil, fmt.Errorf("ip6 pool %s is not IPv6", v6.name())
+		}
+		pools = append(pools, v6)
+	}
+	return newNetworkPool(pools...)
+}
+
+// configuredIPPools builds the default pool plus one per network named in the
+// ip_networks key.
+func configuredIPPools(cfg appConfig) (*ipPoolSet, error) {
+	fallback, err := configuredNetworkPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	set := newIPPoolSet(fallback)
+	for _, entry := range strings.Split(cfg.IPNetworks, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		network, specs, ok := strings.Cut(entry, "=")
+		network = strings.TrimSpace(network)
+		if !ok || network == "" {
+			return nil, fmt.Errorf("ip_networks entry %q is not network=pool", entry)
+		}
+		if _, dup := set.networks[network]; dup {
+			return nil, fmt.Errorf("ip_networks names %s twice", network)
+		}
+		var pools []*ipPool
+		for _, spec := range strings.Split(specs, ";") {
+			pool, err := parseIPPoolSpec(spec)
+			if err != nil {
+				return nil, fmt.Errorf("ip_networks %s: %w", network, err)
+			}
+			pools = append(pools, pool)
+		}
+		n, err := newNetworkPool(pools...)
+		if err != nil {
+			return nil, fmt.Errorf("ip_networks %s: %w", network, err)
+		}
+		set.networks[network] = n
+	}
+	return set, nil
+}
+
+// parseIPPoolSpec reads one ip_networks pool: a CIDR or a start-end range.
+func parseIPPoolSpec(spec string) (*ipPool, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.Contains(spec, "/") {
+		return newIPPoolCIDR(spec)
+	}
+	start, end, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, fmt.Errorf("pool %q is neither a CIDR nor a start-end range", spec)
 	}
+	return newIPPool(start, end)
 }
 
 func (p *ipPool) allocate(serverID string) (string, error) {
@@ -32,6 +163,7 @@ func (p *ipPool) allocate(serverID string) (string, error) {
 		ipStr := ip.String()
 		if _, used := p.allocated[ipStr]; !used {
 			p.allocated[ipStr] = serverID
+			p.since[ipStr] = time.Now().Unix()
 			return ipStr, nil
 		}
 		if ip.Equal(p.end) {
@@ -39,18 +171,19 @@ func (p *ipPool) allocate(serverID string) (string, error) {
 		}
 		incIP(ip)
 	}
-	return "", fmt.Errorf("no IPs available")
+	return "", fmt.Errorf("no %s addresses available", p.family())
 }
 
 func (p *ipPool) release(ip string) {
 	delete(p.allocated, ip)
+	delete(p.since, ip)
 }
 
 func (p *ipPool) releaseByServer(serverID string) {
 	for ip, id := range p.allocated {
 		if id == serverID {
 			delete(p.allocated, ip)
-			return
+			delete(p.since, ip)
 		}
 	}
 }
@@ -64,7 +197,256 @@ func (p *ipPool) reKey(oldID, newID string) {
 }
 
 func (p *ipPool) reserve(ip string, serverID string) {
+	p.restore(ip, serverID, time.Now().Unix())
+}
+
+func (p *ipPool) restore(ip string, serverID string, since int64) {
 	p.allocated[ip] = serverID
+	p.since[ip] = since
+}
+
+func (p *ipPool) name() string {
+	return p.start.String() + "-" + p.end.String()
+}
+
+func (p *ipPool) family() string {
+	if len(p.start) == net.IPv4len {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// contains reports whether ip falls in the range.
+func (p *ipPool) contains(ip net.IP) bool {
+	ip = normalizeIP(ip)
+	return len(ip) == len(p.start) && bytes.Compare(ip, p.start) >= 0 && bytes.Compare(ip, p.end) <= 0
+}
+
+// size counts the addresses in the range, saturating for IPv6 subnets too
+// large for an int.
+func (p *ipPool) size() int {
+	n := new(big.Int).Sub(new(big.Int).SetBytes(p.end), new(big.Int).SetBytes(p.start))
+	n.Add(n, big.NewInt(1))
+	if !n.IsInt64() || n.Int64() > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(n.Int64())
+}
+
+// available counts free addresses in the range.
+func (p *ipPool) available() int {
+	free := p.size()
+	for ipStr := range p.allocated {
+		if p.contains(net.ParseIP(ipStr)) {
+			free--
+		}
+	}
+	return free
+}
+
+// networkPool is the address pools an overlay server draws from, at most one
+// per family. A dual-stack network leases every server one IPv4 and one IPv6
+// address; a single-family network leases one address.
+type networkPool struct {
+	pools []*ipPool // IPv4 first
+}
+
+func newNetworkPool(pools ...*ipPool) (*networkPool, error) {
+	n := &networkPool{}
+	for _, pool := range pools {
+		for _, existing := range n.pools {
+			if existing.family() == pool.family() {
+				return nil, fmt.Errorf("more than one %s pool (%s and %s)", pool.family(), existing.name(), pool.name())
+			}
+		}
+		if pool.family() == "ipv4" {
+			n.pools = append([]*ipPool{pool}, n.pools...)
+		} else {
+			n.pools = append(n.pools, pool)
+		}
+	}
+	if len(n.pools) == 0 {
+		return nil, fmt.Errorf("no IP pool configured")
+	}
+	return n, nil
+}
+
+// allocate leases one address from every pool, IPv4 first, or none at all.
+func (n *networkPool) allocate(serverID string) ([]string, error) {
+	ips := make([]string, 0, len(n.pools))
+	for _, pool := range n.pools {
+		ip, err := pool.allocate(serverID)
+		if err != nil {
+			n.release(ips...)
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+func (n *networkPool) release(ips ...string) {
+	for _, ip := range ips {
+		n.poolFor(ip).release(ip)
+	}
+}
+
+func (n *networkPool) releaseByServer(serverID string) {
+	for _, pool := range n.pools {
+		pool.releaseByServer(serverID)
+	}
+}
+
+func (n *networkPool) reKey(oldID, newID string) {
+	for _, pool := range n.pools {
+		pool.reKey(oldID, newID)
+	}
+}
+
+func (n *networkPool) reserve(ip string, serverID string) {
+	n.poolFor(ip).reserve(ip, serverID)
+}
+
+// holder returns the key leasing ip.
+func (n *networkPool) holder(ip string) (string, bool) {
+	id, held := n.poolFor(ip).allocated[ip]
+	return id, held
+}
+
+// poolFor returns the pool whose range holds ip, else the pool of ip's family,
+// else the first pool, so addresses leased outside every range (containers
+// reconciliation found) are still tracked.
+func (n *networkPool) poolFor(ip string) *ipPool {
+	parsed := net.ParseIP(ip)
+	var sameFamily *ipPool
+	for _, pool := range n.pools {
+		if pool.contains(parsed) {
+			return pool
+		}
+		if parsed != nil && len(normalizeIP(parsed)) == len(pool.start) && sameFamily == nil {
+			sameFamily = pool
+		}
+	}
+	if sameFamily != nil {
+		return sameFamily
+	}
+	return n.pools[0]
+}
+
+// available counts the servers that can still get an address from every pool.
+func (n *networkPool) available() int {
+	free := math.MaxInt
+	for _, pool := range n.pools {
+		free = min(free, pool.available())
+	}
+	return free
+}
+
+func (n *networkPool) all() []*ipPool {
+	return n.pools
+}
+
+// ipPoolSet keys overlay address pools by Docker network, since two overlay
+// networks can use different (or even the same) subnets. Networks without
+// their own pool draw from the fallback, the ip_pool_* pool.
+type ipPoolSet struct {
+	fallback *networkPool
+	networks map[string]*networkPool
+}
+
+func newIPPoolSet(fallback *networkPool) *ipPoolSet {
+	return &ipPoolSet{
+		fallback: fallback,
+		networks: make(map[string]*networkPool),
+	}
+}
+
+// forNetwork returns network's pool, or the fallback when it has none.
+func (s *ipPoolSet) forNetwork(network string) *networkPool {
+	if n, ok := s.networks[network]; ok {
+		return n
+	}
+	return s.fallback
+}
+
+func (s *ipPoolSet) allocate(network, serverID string) ([]string, error) {
+	return s.forNetwork(network).allocate(serverID)
+}
+
+func (s *ipPoolSet) release(network string, ips ...string) {
+	s.forNetwork(network).release(ips...)
+}
+
+func (s *ipPoolSet) releaseByServer(serverID string) {
+	for _, network := range s.names() {
+		s.forNetwork(network).releaseByServer(serverID)
+	}
+}
+
+func (s *ipPoolSet) reKey(oldID, newID string) {
+	for _, network := range s.names() {
+		s.forNetwork(network).reKey(oldID, newID)
+	}
+}
+
+func (s *ipPoolSet) available(network string) int {
+	return s.forNetwork(network).available()
+}
+
+// locate returns the network whose pool leases or contains ip when the
+// caller does not know it, preferring a lease. It returns "" (the fallback)
+// when no network claims ip.
+func (s *ipPoolSet) locate(ip string) string {
+	names := s.names()
+	for _, network := range names {
+		if _, held := s.forNetwork(network).holder(ip); held {
+			return network
+		}
+	}
+	parsed := net.ParseIP(ip)
+	for _, network := range names {
+		for _, pool := range s.forNetwork(network).all() {
+			if pool.contains(parsed) {
+				return network
+			}
+		}
+	}
+	return ""
+}
+
+// names lists the fallback ("") first, then named networks sorted.
+func (s *ipPoolSet) names() []string {
+	names := make([]string, 0, len(s.networks))
+	for network := range s.networks {
+		names = append(names, network)
+	}
+	sort.Strings(names)
+	return append([]string{""}, names...)
+}
+
+// all lists every pool of every network in names order.
+func (s *ipPoolSet) all() []*ipPool {
+	var pools []*ipPool
+	for _, network := range s.names() {
+		pools = append(pools, s.forNetwork(network).all()...)
+	}
+	return pools
+}
+
+func parsePoolIP(s string) net.IP {
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil
+	}
+	return normalizeIP(ip)
+}
+
+// normalizeIP returns IPv4 addresses in their 4-byte form.
+func normalizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
 }
 
 func incIP(ip net.IP) {
@@ -75,3 +457,12 @@ func incIP(ip net.IP) {
 		}
 	}
 }
+
+func decIP(ip net.IP) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]--
+		if ip[i] != 0xff {
+			break
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustNetworkPool(start, end string) *networkPool {
+	pool, err := newIPPool(start, end)
+	if err != nil {
+		panic(err)
+	}
+	n, err := newNetworkPool(pool)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func mustIPPoolSet(start, end string) *ipPoolSet {
+	return newIPPoolSet(mustNetworkPool(start, end))
+}
+
+func TestIPPoolConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]appConfig{
+		"bad address":    {IPStart: "10.0.0.1", IPEnd: "nope"},
+		"mixed families": {IPStart: "10.0.0.1", IPEnd: "fd00::10"},
+		"reversed":       {IPStart: "10.0.0.9", IPEnd: "10.0.0.1"},
+		"bad cidr":       {IPCIDR: "10.0.0.0/33"},
+		"tiny cidr":      {IPCIDR: "10.0.0.0/31"},
+		"v4 as ip6":      {IPStart: "10.0.0.1", IPEnd: "10.0.0.9", IP6CIDR: "10.1.0.0/24"},
+		"two v6 pools":   {IPCIDR: "fd00:1::/64", IP6CIDR: "fd00:2::/64"},
+		"nothing":        {},
+	} {
+		if _, err := configuredNetworkPool(cfg); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestIPPoolCIDRSkipsNetworkGatewayAndBroadcast(t *testing.T) {
+	v4, err := newIPPoolCIDR("10.1.0.0/29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v4.name() != "10.1.0.2-10.1.0.6" || v4.size() != 5 {
+		t.Fatalf("v4 pool = %s size %d", v4.name(), v4.size())
+	}
+	v6, err := newIPPoolCIDR("fd00:99::/64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v6.name() != "fd00:99::2-fd00:99::ffff:ffff:ffff:ffff" || v6.family() != "ipv6" {
+		t.Fatalf("v6 pool = %s (%s)", v6.name(), v6.family())
+	}
+	if free := v6.available(); free <= 0 {
+		t.Fatalf("v6 available = %d", free)
+	}
+}
+
+func TestDualStackAllocation(t *testing.T) {
+	n, err := configuredNetworkPool(appConfig{
+		IPStart: "10.0.0.1", IPEnd: "10.0.0.2",
+		IP6Start: "fd00::a", IP6End: "fd00::f",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ips, err := n.allocate("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ips, ",") != "10.0.0.1,fd00::a" {
+		t.Fatalf("s1 = %v", ips)
+	}
+	if _, err := n.allocate("s2"); err != nil {
+		t.Fatal(err)
+	}
+	if free := n.available(); free != 0 {
+		t.Fatalf("available = %d, want 0 once IPv4 is exhausted", free)
+	}
+	if _, err := n.allocate("s3"); err == nil {
+		t.Fatal("allocated past the IPv4 pool")
+	}
+	if id, held := n.holder("fd00::c"); held {
+		t.Fatalf("failed allocation kept fd00::c for %s", id)
+	}
+
+	n.reKey("s1", "c1")
+	n.releaseByServer("c1")
+	if _, held := n.holder("10.0.0.1"); held {
+		t.Fatal("releaseByServer kept 10.0.0.1")
+	}
+	if _, held := n.holder("fd00::a"); held {
+		t.Fatal("releaseByServer kept fd00::a")
+	}
+}
+
+func TestPerNetworkIPPools(t *testing.T) {
+	set, err := configuredIPPools(appConfig{
+		IPStart:    "10.99.0.10",
+		IPEnd:      "10.99.0.250",
+		IPNetworks: "games-a=10.98.0.0/29;fd00:98::/120, games-b=10.98.0.2-10.98.0.3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(set.names(), ","); got != ",games-a,games-b" {
+		t.Fatalf("names = %q", got)
+	}
+	a, err := set.allocate("games-a", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := set.allocate("games-b", "s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Overlapping subnets on different networks lease independently.
+	if a[0] != "10.98.0.2" || b[0] != "10.98.0.2" || len(a) != 2 {
+		t.Fatalf("games-a = %v, games-b = %v", a, b)
+	}
+	other, err := set.allocate("unconfigured", "s3")
+	if err != nil || other[0] != "10.99.0.10" {
+		t.Fatalf("unconfigured network = %v, %v", other, err)
+	}
+	if free := set.available("games-b"); free != 1 {
+		t.Fatalf("games-b free = %d, want 1", free)
+	}
+	if network := set.locate("fd00:98::2"); network != "games-a" {
+		t.Fatalf("locate = %q", network)
+	}
+
+	set.releaseByServer("s2")
+	if _, held := set.forNetwork("games-b").holder("10.98.0.2"); held {
+		t.Fatal("releaseByServer kept the games-b lease")
+	}
+	if _, held := set.forNetwork("games-a").holder("10.98.0.2"); !held {
+		t.Fatal("releasing games-b freed games-a's address")
+	}
+
+	for _, bad := range []string{"games", "=10.0.0.0/24", "a=10.0.0.1", "a=10.0.0.0/24,a=10.1.0.0/24"} {
+		if _, err := configuredIPPools(appConfig{IPStart: "10.99.0.10", IPEnd: "10.99.0.250", IPNetworks: bad}); err == nil {
+			t.Errorf("ip_networks %q accepted", bad)
+		}
+	}
+}
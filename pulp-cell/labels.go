@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+)
+
+// Ownership labels stamped on every container the cell creates. They let
+// reconciliation, capacity accounting and listing identify a server without
+// relying on its Docker name, which caller-supplied server IDs and template
+// renames both break.
+const (
+	templateLabel = "bananagine.template"
+	serverIDLabel = "bananagine.server-id"
+	ownerLabel    = "bananagine.owner"
+	tierLabel     = "bananagine.tier"
+	ipv6Label     = "bananagine.ipv6"
+	networkLabel  = "bananagine.network"
+)
+
+func serverLabels(template, serverID, owner, tier string) map[string]string {
+	labels := map[string]string{
+		templateLabel: template,
+		serverIDLabel: serverID,
+	}
+	if owner != "" {
+		labels[ownerLabel] = owner
+	}
+	if tier != "" {
+		labels[tierLabel] = tier
+	}
+	return labels
+}
+
+// labeled returns server with the labels recorded when it was adopted. Docker
+// cannot relabel an existing container, so an adopted container carries its
+// ownership labels only here; see adopt.go. Labels Docker reports win.
+func (p *provisioner) labeled(server docker.Server) docker.Server {
+	overlay := p.labels[server.ID]
+	if len(overlay) == 0 {
+		return server
+	}
+	merged := make(map[string]string, len(server.Labels)+len(overlay))
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	for k, v := range server.Labels {
+		merged[k] = v
+	}
+	server.Labels = merged
+	return server
+}
+
+// templateOf returns the template a container was created from. Containers
+// created before labels existed fall back to the longest loaded template name
+// that prefixes their Docker name.
+func (p *provisioner) templateOf(server docker.Server) string {
+	server = p.labeled(server)
+	if template := server.Labels[templateLabel]; template != "" {
+		return template
+	}
+	name := strings.TrimPrefix(server.Name, "/")
+	match := ""
+	for _, tmpl := range p.templates {
+		if strings.HasPrefix(name, tmpl.Name+"-") && len(tmpl.Name) > len(match) {
+			match = tmpl.Name
+		}
+	}
+	return match
+}
+
+// ownerOf returns a container's tenant. Claimed warm-pool standbys were created
+// ownerless, so their owner comes from the quota charge made at claim time.
+func (p *provisioner) ownerOf(server docker.Server) string {
+	server = p.labeled(server)
+	if owner := server.Labels[ownerLabel]; owner != "" {
+		return owner
+	}
+	return p.quotas.servers[server.ID].owner
+}
+
+// manages reports whether a container is a Bananagine server.
+func (p *provisioner) manages(server docker.Server) bool {
+	return p.templateOf(server) != ""
+}
+
+// filterServers keeps servers matching every non-empty filter, with their
+// adoption labels applied.
+func (p *provisioner) filterServers(servers []docker.Server, template, owner string) []docker.Server {
+	kept := make([]docker.Server, 0, len(servers))
+	for _, server := range servers {
+		server = p.labeled(server)
+		if template != "" && p.templateOf(server) != template {
+			continue
+		}
+		if owner != "" && p.ownerOf(server) != owner {
+			continue
+		}
+		kept = append(kept, server)
+	}
+	return kept
+}
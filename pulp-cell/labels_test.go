@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+func TestServerLabels(t *testing.T) {
+	labels := serverLabels("paper", "mc-1", "", "")
+	if len(labels) != 2 || labels[templateLabel] != "paper" || labels[serverIDLabel] != "mc-1" {
+		t.Fatalf("labels = %v", labels)
+	}
+	labels = serverLabels("paper", "mc-1", "acme", "large")
+	if labels[ownerLabel] != "acme" || labels[tierLabel] != "large" {
+		t.Fatalf("labels = %v", labels)
+	}
+}
+
+func TestTemplateOfPrefersLabels(t *testing.T) {
+	p := &provisioner{templates: map[string]Template{
+		"paper":      {Name: "paper"},
+		"paper-1.21": {Name: "paper-1.21"},
+	}}
+	tests := []struct {
+		name   string
+		server docker.Server
+		want   string
+	}{
+		{name: "label wins over name", server: docker.Server{Name: "/paper-x", Labels: map[string]string{templateLabel: "renamed"}}, want: "renamed"},
+		{name: "caller server id", server: docker.Server{Name: "/customer-world", Labels: map[string]string{templateLabel: "paper"}}, want: "paper"},
+		{name: "legacy longest prefix", server: docker.Server{Name: "/paper-1.21-42"}, want: "paper-1.21"},
+		{name: "unmanaged", server: docker.Server{Name: "/postgres"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.templateOf(tt.server); got != tt.want {
+				t.Fatalf("templateOf = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterServers(t *testing.T) {
+	p := &provisioner{
+		templates: map[string]Template{"paper": {Name: "paper"}},
+		quotas:    newQuotaTracker(map[string]orchestration.TenantQuota{}, "worlds"),
+	}
+	p.quotas.charge("claimed", "acme", "paper-standby-1", 1, 0)
+	servers := []docker.Server{
+		{ID: "a", Name: "/one", Labels: map[string]string{templateLabel: "paper", ownerLabel: "acme"}},
+		{ID: "b", Name: "/two", Labels: map[string]string{templateLabel: "bedrock", ownerLabel: "acme"}},
+		{ID: "claimed", Name: "/paper-standby-1"},
+		{ID: "c", Name: "/paper-3", Labels: map[string]string{templateLabel: "paper", ownerLabel: "other"}},
+	}
+	ids := func(servers []docker.Server) []string {
+		var out []string
+		for _, s := range servers {
+			out = append(out, s.ID)
+		}
+		return out
+	}
+	if got := ids(p.filterServers(servers, "paper", "acme")); len(got) != 2 || got[0] != "a" || got[1] != "claimed" {
+		t.Fatalf("paper+acme = %v", got)
+	}
+	if got := ids(p.filterServers(servers, "", "other")); len(got) != 1 || got[0] != "c" {
+		t.Fatalf("owner other = %v", got)
+	}
+	if got := p.filterServers(servers, "", ""); len(got) != 4 {
+		t.Fatalf("unfiltered = %v", ids(got))
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+const (
+	serverLeasesPath   = "server-leases.json"
+	leaseSweepInterval = 5 * time.Second
+)
+
+// leaseTracker destroys servers whose time-to-live lapsed. Ephemeral servers
+// otherwise outlive an orchestrator that crashed before deleting them. Leases
+// are keyed by container ID and persisted so a cell restart keeps sweeping.
+type leaseTracker struct {
+	prov *provisioner
+	// release drops a destroyed container from the cell's trackers,
+	// this one included.
+	release   func(containerID string)
+	leases    map[string]orchestration.ServerLease
+	nextSweep time.Time
+}
+
+func newLeaseTracker(prov *provisioner, release func(string)) *leaseTracker {
+	return &leaseTracker{
+		prov:    prov,
+		release: release,
+		leases:  make(map[string]orchestration.ServerLease),
+	}
+}
+
+func (l *leaseTracker) load() error {
+	var leases map[string]orchestration.ServerLease
+	if found, err := loadCellState(serverLeasesPath, &leases); err != nil || !found {
+		return err
+	}
+	for id, lease := range leases {
+		if !containerGone(id) {
+			l.leases[id] = lease
+		}
+	}
+	return l.persist()
+}
+
+func (l *leaseTracker) persist() error {
+	return storeCellState(serverLeasesPath, l.leases)
+}
+
+// grant leases a newly created server. An existing lease is left untouched so
+// an idempotent create retry never extends it; renew is the only extension.
+func (l *leaseTracker) grant(containerID, serverID string, expiresAt int64) {
+	if _, leased := l.leases[containerID]; leased {
+		return
+	}
+	l.leases[containerID] = orchestration.ServerLease{ID: containerID, ServerID: serverID, ExpiresAt: expiresAt}
+	if err := l.persist(); err != nil {
+		log.Printf("[Lease] persist leases: %v", err)
+	}
+}
+
+func (l *leaseTracker) renew(containerID string, expiresAt int64) (orchestration.ServerLease, bool) {
+	lease, leased := l.leases[containerID]
+	if !leased {
+		return orchestration.ServerLease{}, false
+	}
+	lease.ExpiresAt = expiresAt
+	l.leases[containerID] = lease
+	if err := l.persist(); err != nil {
+		log.Printf("[Lease] persist leases: %v", err)
+	}
+	return lease, true
+}
+
+func (l *leaseTracker) forget(containerID string) {
+	if _, leased := l.leases[containerID]; !leased {
+		return
+	}
+	delete(l.leases, containerID)
+	if err := l.persist(); err != nil {
+		log.Printf("[Lease] persist leases: %v", err)
+	}
+}
+
+// sweep destroys every server whose lease lapsed by now. It runs from the step
+// loop, throttled to leaseSweepInterval. A destroy that fails for any reason
+// but not-found keeps the lease so the next sweep retries it.
+func (l *leaseTracker) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	l.nextSweep = now.Add(leaseSweepInterval)
+	for _, lease := range expiredLeases(l.leases, now.Unix()) {
+		// Best-effort save flush; templates without rcon simply fail the exec.
+		if _, err := docker.Exec(lease.ID, []string{"rcon", "save-all flush"}); err != nil && !isDockerNotFound(err) {
+			log.Printf("[Lease] flush before expiry of %s: %v", lease.ServerID, err)
+		}
+		if err := docker.Destroy(lease.ID); err != nil && !isDockerNotFound(err) {
+			log.Printf("[Lease] destroy expired %s: %v", lease.ServerID, err)
+			continue
+		}
+		l.prov.release(lease.ID)
+		l.release(lease.ID)
+		log.Printf("[Lease] %s expired and was destroyed", lease.ServerID)
+		emitOrchestrationEvent(wireEvent{
+			ContainerID: lease.ID,
+			Name:        lease.ServerID,
+			Action:      "expired",
+			Time:        now.UnixNano(),
+		})
+	}
+}
+
+// expiredLeases returns leases lapsed at nowUnix, soonest expiry first.
+func expiredLeases(leases map[string]orchestration.ServerLease, nowUnix int64) []orchestration.ServerLease {
+	var expired []orchestration.ServerLease
+	for _, lease := range leases {
+		if lease.ExpiresAt <= nowUnix {
+			expired = append(expired, lease)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].ExpiresAt == expired[j].ExpiresAt {
+			return expired[i].ID < expired[j].ID
+		}
+		return expired[i].ExpiresAt < expired[j].ExpiresAt
+	})
+	return expired
+}
+
+// leaseExpiry resolves a relative or absolute lease to unix seconds. Zero means
+// the request asked for no lease.
+func leaseExpiry(ttlSeconds, expiresAt int64, now time.Time) (int64, error) {
+	switch {
+	case ttlSeconds < 0 || expiresAt < 0:
+		return 0, errors.New("ttl_seconds and expires_at cannot be negative")
+	case ttlSeconds > 0 && expiresAt > 0:
+		return 0, errors.New("set only one of ttl_seconds or expires_at")
+	case ttlSeconds > 0:
+		return now.Unix() + ttlSeconds, nil
+	case expiresAt > 0:
+		if expiresAt <= now.Unix() {
+			return 0, errors.New("expires_at must be in the future")
+		}
+		return expiresAt, nil
+	default:
+		return 0, nil
+	}
+}
+
+func registerLeaseRoutes(group *pulpgin.RouterGroup, leases *leaseTracker) {
+	group.POST("/servers/:id/renew", func(c *pulpgin.Context) {
+		id := leases.prov.containerFor(strings.TrimSpace(c.Param("id")))
+		var req orchestration.RenewLeaseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		expiresAt, err := leaseExpiry(req.TTLSeconds, req.ExpiresAt, time.Now())
+		if err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		if expiresAt == 0 {
+			c.JSON(400, pulpgin.H{"error": "ttl_seconds or expires_at is required"})
+			return
+		}
+		lease, ok := leases.renew(id, expiresAt)
+		if !ok {
+			c.JSON(404, pulpgin.H{"error": "server has no lease"})
+			return
+		}
+		c.JSON(200, lease)
+	})
+}
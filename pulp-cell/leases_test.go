@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+func TestLeaseExpiryResolvesRelativeAndAbsoluteLeases(t *testing.T) {
+	now := time.Unix(1_000, 0)
+	tests := []struct {
+		name      string
+		ttl       int64
+		expiresAt int64
+		want      int64
+		wantError string
+	}{
+		{name: "no lease", want: 0},
+		{name: "ttl", ttl: 60, want: 1_060},
+		{name: "absolute", expiresAt: 2_000, want: 2_000},
+		{name: "both", ttl: 60, expiresAt: 2_000, wantError: "only one"},
+		{name: "negative", ttl: -1, wantError: "negative"},
+		{name: "past", expiresAt: 1_000, wantError: "future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := leaseExpiry(tt.ttl, tt.expiresAt, now)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("leaseExpiry() error = %v, want %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("leaseExpiry() = (%d, %v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredLeasesAreDueOnlyAndSoonestFirst(t *testing.T) {
+	leases := map[string]orchestration.ServerLease{
+		"c-late":   {ID: "c-late", ExpiresAt: 90},
+		"c-early":  {ID: "c-early", ExpiresAt: 50},
+		"c-edge":   {ID: "c-edge", ExpiresAt: 100},
+		"c-future": {ID: "c-future", ExpiresAt: 101},
+	}
+	var got []string
+	for _, lease := range expiredLeases(leases, 100) {
+		got = append(got, lease.ID)
+	}
+	if want := []string{"c-early", "c-late", "c-edge"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expired = %v, want %v", got, want)
+	}
+}
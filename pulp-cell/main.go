@@ -33,7 +33,6 @@ import (
 
 	"bananagine-cell/execallow"
 	"bananagine-cell/registryproxy"
-	"bananagine-cell/resources"
 )
 
 // orchestrationEventsPath is the SSE route for container lifecycle events.
@@ -86,6 +85,21 @@ func toWireEvent(de docker.Event) wireEvent {
 	}
 }
 
+// emitOrchestrationEvent fans a cell-originated lifecycle event, one Docker
+// never reports such as a lease expiry, out over the same SSE route.
+func emitOrchestrationEvent(event wireEvent) {
+	if !pulp.SSE.HasSubscribers(orchestrationEventsPath) {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := pulp.SSE.Emit(orchestrationEventsPath, "", "", string(payload)); err != nil {
+		log.Printf("[Events] SSE emit failed: %v", err)
+	}
+}
+
 func toOrchestrationServer(server docker.Server) orchestration.Server {
 	return orchestration.Server{
 		ID:          server.ID,
@@ -95,6 +109,7 @@ func toOrchestrationServer(server docker.Server) orchestration.Server {
 		Ports:       server.Ports,
 		CPULimit:    server.CPULimit,
 		MemoryLimit: server.MemoryLimit,
+		Labels:      server.Labels,
 	}
 }
 
@@ -145,6 +160,28 @@ type appConfig struct {
 	CPUBudget     float64
 	MemBudget     float64
 	WorldsDir     string
+	// QuotasFile names a YAML file on the cell FS mapping owner to tenant
+	// limits; see quotas.go. Empty configures no quotas.
+	QuotasFile string
+
+	// IPCIDR replaces IPStart/IPEnd when set. The IP6 keys add an IPv6 pool
+	// alongside, making overlay servers dual-stack; see ips.go.
+	IPCIDR   string
+	IP6Start string
+	IP6End   string
+	IP6CIDR  string
+	// IPNetworks gives named Docker networks their own pools:
+	// "net=pool[;pool],..." where a pool is a CIDR or start-end range.
+	IPNetworks string
+	// PortReuseCooldown withholds a released port from other servers; see
+	// ports.go.
+	PortReuseCooldown time.Duration
+
+	// GC thresholds and allowlist; see gc.go. Ages count from when the
+	// collector first saw a candidate orphaned.
+	GCMinAge     time.Duration
+	GCReceiptTTL time.Duration
+	GCAllowlist  []string
 
 	// TemplatesDir is the build context passed to docker.Build when the
 	// /admin/build-image endpoint is hit. Mirrors the original service's
@@ -172,6 +209,12 @@ func parseConfig(data []byte) (appConfig, error) {
 		Templates     string  `json:"templates"`
 		IPStart       string  `json:"ip_pool_start"`
 		IPEnd         string  `json:"ip_pool_end"`
+		IPCIDR        string  `json:"ip_pool_cidr"`
+		IP6Start      string  `json:"ip6_pool_start"`
+		IP6End        string  `json:"ip6_pool_end"`
+		IP6CIDR       string  `json:"ip6_pool_cidr"`
+		IPNetworks    string  `json:"ip_networks"`
+		PortCooldown  int     `json:"port_reuse_cooldown_seconds"`
 		PortStart     int     `json:"port_pool_start"`
 		PortEnd       int     `json:"port_pool_end"`
 		ExternalHost  string  `json:"external_host"`
@@ -179,6 +222,10 @@ func parseConfig(data []byte) (appConfig, error) {
 		CPUBudget     float64 `json:"cpu_budget"`
 		MemBudget     float64 `json:"memory_budget"`
 		WorldsDir     string  `json:"worlds_dir"`
+		Quotas        string  `json:"quotas"`
+		GCMinAgeHours int     `json:"gc_min_age_hours"`
+		GCReceiptTTL  int     `json:"gc_receipt_ttl_hours"`
+		GCAllowlist   string  `json:"gc_allowlist"`
 		TemplatesDir  string  `json:"templates_dir"`
 		NodeCPUCores  int     `json:"node_cpu_cores"`
 		NodeTotalMem  uint64  `json:"node_total_memory"`
@@ -199,6 +246,12 @@ func parseConfig(data []byte) (appConfig, error) {
 	if cfg.IPEnd == "" {
 		cfg.IPEnd = "10.99.0.250"
 	}
+	cfg.IPCIDR = tmp.IPCIDR
+	cfg.IP6Start = tmp.IP6Start
+	cfg.IP6End = tmp.IP6End
+	cfg.IP6CIDR = tmp.IP6CIDR
+	cfg.IPNetworks = tmp.IPNetworks
+	cfg.PortReuseCooldown = time.Duration(tmp.PortCooldown) * time.Second
 	cfg.PortStart = tmp.PortStart
 	if cfg.PortStart == 0 {
 		cfg.PortStart = 5521
@@ -219,6 +272,20 @@ func parseConfig(data []byte) (appConfig, error) {
 		// and normalize at use time.
 		cfg.WorldsDir = "/var/sessions/worlds"
 	}
+	cfg.QuotasFile = tmp.Quotas
+	cfg.GCMinAge = time.Duration(tmp.GCMinAgeHours) * time.Hour
+	if cfg.GCMinAge <= 0 {
+		cfg.GCMinAge = defaultGCMinAge
+	}
+	cfg.GCReceiptTTL = time.Duration(tmp.GCReceiptTTL) * time.Hour
+	if cfg.GCReceiptTTL <= 0 {
+		cfg.GCReceiptTTL = defaultGCReceiptTTL
+	}
+	for _, pattern := range strings.Split(tmp.GCAllowlist, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			cfg.GCAllowlist = append(cfg.GCAllowlist, pattern)
+		}
+	}
 	cfg.TemplatesDir = tmp.TemplatesDir
 	if cfg.TemplatesDir == "" {
 		cfg.TemplatesDir = "/app/templates"
@@ -302,7 +369,7 @@ func bootstrap(configBytes []byte) error {
 		return fmt.Errorf("SERVICE_TOKEN is required: refusing to start with auth disabled")
 	}
 
-	templates, err := loadTemplates(cfg.TemplateFiles)
+	templates, rawTemplates, err := loadTemplates(cfg.TemplateFiles)
 	if err != nil {
 		return fmt.Errorf("load templates: %w", err)
 	}
@@ -314,7 +381,6 @@ func bootstrap(configBytes []byte) error {
 	// stderr see the same lines across native + cell deployments.
 	fmt.Printf("CPU budget: %.2f cores\n", cfg.CPUBudget)
 	fmt.Printf("Memory budget: %.2f GiB\n", cfg.MemBudget)
-	fmt.Printf("IP pool: %s - %s\n", cfg.IPStart, cfg.IPEnd)
 	fmt.Printf("Port pool: %d - %d\n", cfg.PortStart, cfg.PortEnd)
 	if cfg.ExternalHost != "" {
 		fmt.Printf("External host: %s\n", cfg.ExternalHost)
@@ -345,9 +411,27 @@ func bootstrap(configBytes []byte) error {
 	}
 
 	capacity := newCapacityTracker(cfg.CPUBudget, cfg.MemBudget)
-	ipp := newIPPool(cfg.IPStart, cfg.IPEnd)
+	ipp, err := configuredIPPools(cfg)
+	if err != nil {
+		return fmt.Errorf("ip pool: %w", err)
+	}
+	for _, network := range ipp.names() {
+		label := network
+		if label == "" {
+			label = "default"
+		}
+		for _, pool := range ipp.forNetwork(network).all() {
+			fmt.Printf("IP pool %s (%s): %s\n", label, pool.family(), pool.name())
+		}
+	}
 	fallback := newPortPool(cfg.PortStart, cfg.PortEnd)
 	portPools := newPortPoolSet(fallback)
+	portPools.setCooldown(cfg.PortReuseCooldown)
+	quotaLimits, err := loadQuotas(cfg.QuotasFile)
+	if err != nil {
+		return err
+	}
+	quotas := newQuotaTracker(quotaLimits, resolveWorldsRoot(cfg.WorldsDir))
 
 	for _, tmpl := range templates {
 		for _, p := range tmpl.Container.Ports {
@@ -359,31 +443,40 @@ func bootstrap(configBytes []byte) error {
 		}
 	}
 
-	// Reconcile with already-running containers
+	prov := &provisioner{
+		cfg:          cfg,
+		templates:    templates,
+		bindings:     make(map[string]string),
+		labels:       make(map[string]map[string]string),
+		adoptions:    make(map[string]adoption),
+		capacity:     capacity,
+		ipp:          ipp,
+		portPools:    portPools,
+		quotas:       quotas,
+		reservations: newReservationBook(capacity, portPools),
+		rendered:     make(map[string]renderedFiles),
+		rawTemplates: rawTemplates,
+		kept:         make(map[string]struct{}),
+	}
+
+	// Adopted containers carry their ownership labels in cell state, so load
+	// them before reconciliation charges capacity; see adopt.go.
+	if err := prov.loadAdoptions(); err != nil {
+		log.Printf("[Adopt] failed to restore adoptions: %v", err)
+	}
+	if err := prov.loadRenderedFiles(); err != nil {
+		log.Printf("[Files] failed to restore rendered files: %v", err)
+	}
+
+	// Reconcile with already-running containers. The step loop repeats this
+	// periodically; see reconcile.go.
 	if existing, err := docker.List(nil); err == nil {
 		reconciled := 0
 		for _, s := range existing {
-			for _, p := range s.Ports {
-				portPools.reserve(p, s.ID)
-			}
-			if s.IP != "" {
-				ipp.reserve(s.IP, s.ID)
+			if _, err := prov.adopt(s, true); err != nil {
+				log.Printf("[Reconcile] capacity allocation failed for %s: %v", s.ID, err)
 			}
-			name := strings.TrimPrefix(s.Name, "/")
-			matched := false
-			for _, tmpl := range templates {
-				if strings.HasPrefix(name, tmpl.Name+"-") {
-					matched = true
-					break
-				}
-			}
-			if !matched {
-				continue
-			}
-			if s.CPULimit > 0 || s.MemoryLimit > 0 {
-				if err := capacity.tryAllocate(s.ID, s.CPULimit, s.MemoryLimit); err != nil {
-					log.Printf("[Reconcile] capacity allocation failed for %s: %v", s.ID, err)
-				}
+			if prov.manages(s) && (s.CPULimit > 0 || s.MemoryLimit > 0) {
 				reconciled++
 			}
 		}
@@ -393,6 +486,48 @@ func bootstrap(configBytes []byte) error {
 		}
 	}
 
+	ledger := newAllocationLedger(capacity, ipp, portPools, prov.kept)
+	if restored, err := ledger.restore(); err != nil {
+		log.Printf("[Allocations] failed to restore ledger: %v", err)
+	} else if restored > 0 {
+		fmt.Printf("Restored %d allocation leases from the ledger\n", restored)
+	}
+
+	warm := newWarmPool(prov)
+	if err := warm.load(); err != nil {
+		log.Printf("[WarmPool] failed to restore state: %v", err)
+	}
+	warm.refill()
+
+	idle := newIdleTracker(prov)
+	if err := idle.load(); err != nil {
+		log.Printf("[Idle] failed to restore state: %v", err)
+	}
+	fleetBeforeResume = idle.readmit
+	fleetReconfigureSettings = prov.reconfigureSettings
+	fleetResolveContainer = prov.containerFor
+
+	// forget drops a removed container from every tracker that is not an
+	// allocator. Each caller releases allocations its own way.
+	var leases *leaseTracker
+	forget := func(containerID string) {
+		warm.forget(containerID)
+		leases.forget(containerID)
+		idle.forget(containerID)
+		prov.forgetAdoption(containerID)
+		prov.forgetRenderedFiles(containerID)
+	}
+	leases = newLeaseTracker(prov, forget)
+	if err := leases.load(); err != nil {
+		log.Printf("[Lease] failed to restore leases: %v", err)
+	}
+	drift := newDriftReconciler(prov, idle, forget, time.Now())
+
+	gc := newGCCollector(prov, resolveWorldsRoot(cfg.WorldsDir), cfg, forget)
+	if err := gc.load(); err != nil {
+		log.Printf("[GC] failed to restore state: %v", err)
+	}
+
 	r := pulpgin.New()
 
 	// --- Health & Templates ---
@@ -406,7 +541,12 @@ func bootstrap(configBytes []byte) error {
 	})
 
 	r.GET("/templates", func(c *pulpgin.Context) {
-		result, err := callTemplateCatalog[templatecatalog.Catalog](templatecatalog.FnList, map[string]any{})
+		filter := templatecatalog.ListRequest{
+			Game:   c.Query("game"),
+			Engine: c.Query("engine"),
+			Label:  c.Query("label"),
+		}
+		result, err := callTemplateCatalog[templatecatalog.Catalog](templatecatalog.FnList, filter)
 		if err != nil {
 			log.Printf("[TemplateCatalog] composition unavailable: %v", err)
 			c.JSON(503, pulpgin.H{"error": "template catalog unavailable"})
@@ -427,6 +567,19 @@ func bootstrap(configBytes []byte) error {
 				CPULimit: entry.CPULimit, MemoryLimit: entry.MemoryLimit,
 			})
 		}
+		// The bare array stays the default shape; facets need a wrapper.
+		if c.Query("facets") == "true" {
+			games, engines := []templatecatalog.FacetCount{}, []templatecatalog.FacetCount{}
+			if facets := result.Value.Facets; facets != nil {
+				games = append(games, facets.Games...)
+				engines = append(engines, facets.Engines...)
+			}
+			c.JSON(200, pulpgin.H{
+				"templates": entries,
+				"facets":    pulpgin.H{"games": games, "engines": engines},
+			})
+			return
+		}
 		c.JSON(200, entries)
 	})
 
@@ -465,6 +618,34 @@ func bootstrap(configBytes []byte) error {
 		c.Data(200, "application/json; charset=utf-8", result.Value.ConfigJSON)
 	})
 
+	r.POST("/templates/:name/validate", func(c *pulpgin.Context) {
+		var req templatecatalog.ValidateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		req.Name = c.Param("name")
+		result, err := callTemplateCatalog[templatecatalog.Validation](templatecatalog.FnValidate, req)
+		if err != nil {
+			log.Printf("[TemplateCatalog] composition unavailable: %v", err)
+			c.JSON(503, pulpgin.H{"error": "template catalog unavailable"})
+			return
+		}
+		if !result.OK {
+			if result.Error != nil && result.Error.Code == templatecatalog.CodeNotFound {
+				c.JSON(404, pulpgin.H{"error": "template not found"})
+				return
+			}
+			message := "template catalog failed"
+			if result.Error != nil && result.Error.Message != "" {
+				message = result.Error.Message
+			}
+			c.JSON(500, pulpgin.H{"error": message})
+			return
+		}
+		c.JSON(200, result.Value)
+	})
+
 	// --- Orchestration ---
 
 	// Mirror upstream cmd/server/main.go bootstrap log. The empty-token case
@@ -480,6 +661,7 @@ func bootstrap(configBytes []byte) error {
 			c.JSON(500, pulpgin.H{"error": err.Error()})
 			return
 		}
+		servers = prov.filterServers(servers, c.Query("template"), c.Query("owner"))
 		// Upstream cmd/server initializes `servers := []orchestrator.Server{}`
 		// so an empty list marshals as `[]`, not `null`. docker.List returns
 		// nil on empty, so coerce before encoding to preserve the wire shape.
@@ -487,7 +669,7 @@ func bootstrap(configBytes []byte) error {
 	})
 
 	orch.GET("/servers/:id", func(c *pulpgin.Context) {
-		id := c.Param("id")
+		id := prov.containerFor(c.Param("id"))
 		// docker.Get maps to provider.Get (Docker ContainerInspect), which
 		// accepts either container ID or name. Matches native cmd/server
 		// line 397-410 — returns 404 when the container doesn't exist,
@@ -501,7 +683,7 @@ func bootstrap(configBytes []byte) error {
 			c.JSON(500, pulpgin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(200, toOrchestrationServer(*server))
+		c.JSON(200, toOrchestrationServer(prov.labeled(*server)))
 	})
 
 	orch.POST("/servers", func(c *pulpgin.Context) {
@@ -510,295 +692,33 @@ func bootstrap(configBytes []byte) error {
 			c.JSON(400, pulpgin.H{"error": err.Error()})
 			return
 		}
-
-		// Evolution retries provision requests with the same ServerID. Resolve
-		// that exact Docker name before looking up templates, allocating a port
-		// or IP, calling hooks, or consuming capacity. A retry therefore has the
-		// same successful create shape but never replays provisioning side effects.
-		if req.ServerID != "" {
-			existing, found, err := existingServerForRequestedID(req.ServerID, docker.Get)
-			if err != nil {
-				c.JSON(500, pulpgin.H{"error": err.Error()})
-				return
-			}
-			if found {
-				server := orchestrationResponseServer(*existing, req.ServerID, cfg.ExternalHost)
-				c.JSON(201, toOrchestrationServer(server))
-				return
-			}
-		}
-
-		tmpl, ok := templates[req.Template]
-		if !ok {
-			c.JSON(404, pulpgin.H{"error": "template not found"})
-			return
-		}
-
-		container := deepCopyContainer(tmpl.Container)
-
-		// Platform-aware port filtering. A game template can declare ports for
-		// several player platforms (minecraft: java + bedrock), but a server only
-		// exposes the platforms its selected engine supports. Without this, EVERY
-		// minecraft server got a bedrock port even for a Java-only engine with
-		// crossplay off — a dead Geyser port (mc-f7613, 2026-07-20). We keep a
-		// platform-named port (java/bedrock) only when that platform is active for
-		// the chosen engine; crossplay extends a Java engine to also serve Bedrock.
-		if len(tmpl.Config.Engines) > 0 && len(container.Ports) > 0 {
-			active := map[string]bool{}
-			for _, e := range tmpl.Config.Engines {
-				if e.Value == req.Env["ENGINE"] {
-					for _, p := range e.Platforms {
-						active[p] = true
-					}
-					break
-				}
-			}
-			if req.Env["_CROSSPLAY"] == "true" {
-				active["bedrock"] = true
-			}
-			// Only filter once we've resolved the engine's platforms; an unknown or
-			// absent engine keeps every port (safe back-compat for games without a
-			// platform model, and for older callers that don't send ENGINE).
-			if len(active) > 0 {
-				kept := container.Ports[:0]
-				for _, p := range container.Ports {
-					if (p.Name == "java" || p.Name == "bedrock") && !active[p.Name] {
-						continue
-					}
-					kept = append(kept, p)
-				}
-				container.Ports = kept
-			}
-		}
-
-		serverID := req.ServerID
-		if serverID == "" {
-			serverID = fmt.Sprintf("%s-%d", req.Template, time.Now().UnixNano())
-		}
-
-		// Expand volume path templates
-		var volumeExpansions [][2]string
-		for hostPath, containerPath := range container.Volumes {
-			if strings.Contains(hostPath, "{{SERVER_ID}}") {
-				volumeExpansions = append(volumeExpansions, [2]string{hostPath, containerPath})
-			}
-		}
-		for _, exp := range volumeExpansions {
-			delete(container.Volumes, exp[0])
-			container.Volumes[strings.ReplaceAll(exp[0], "{{SERVER_ID}}", serverID)] = exp[1]
-		}
-
-		if container.Environment == nil {
-			container.Environment = make(map[string]string)
-		}
-		for k, v := range tmpl.Server {
-			container.Environment[k] = v
-		}
-
-		var allocatedIP string
-		var allocatedPort int
-
-		if container.Network != "" {
-			ip, err := ipp.allocate(serverID)
-			if err != nil {
-				c.JSON(503, pulpgin.H{"error": err.Error()})
-				return
-			}
-			allocatedIP = ip
-			container.IP = ip
-
-			allocatedPort = 5520
-			if len(container.Ports) > 0 {
-				allocatedPort = container.Ports[0].Container
-			}
-
-			container.Environment["SERVER_HOST"] = ip
-			for _, p := range container.Ports {
-				if p.Name != "" {
-					container.Environment["PORT_"+strings.ToUpper(p.Name)] = fmt.Sprintf("%d", p.Container)
-				}
-			}
-
-			fmt.Printf("Overlay mode: %s -> %s:%d\n", serverID, ip, allocatedPort)
-		} else {
-			var allocatedPorts []int
-			for i := range container.Ports {
-				port, err := portPools.allocate(container.Ports[i].Range, serverID)
-				if err != nil {
-					portPools.releaseByServer(serverID)
-					c.JSON(503, pulpgin.H{"error": err.Error()})
-					return
-				}
-				allocatedPorts = append(allocatedPorts, port)
-				container.Ports[i].Host = port
-				container.Ports[i].Container = port
-			}
-			if len(allocatedPorts) == 0 {
-				port, err := portPools.allocate("", serverID)
-				if err != nil {
-					c.JSON(503, pulpgin.H{"error": err.Error()})
-					return
-				}
-				allocatedPorts = append(allocatedPorts, port)
-			}
-			allocatedPort = allocatedPorts[0]
-
-			container.Environment["SERVER_HOST"] = "0.0.0.0"
-			for i, p := range container.Ports {
-				if p.Name != "" {
-					container.Environment["PORT_"+strings.ToUpper(p.Name)] = fmt.Sprintf("%d", allocatedPorts[i])
-				}
-			}
-
-			fmt.Printf("Host mode: %s -> 0.0.0.0:%d\n", serverID, allocatedPort)
-		}
-
-		container.Environment["SERVER_PORT"] = fmt.Sprintf("%d", allocatedPort)
-		container.Environment["SERVER_ID"] = serverID
-
-		releaseResources := func() {
-			if allocatedIP != "" {
-				ipp.release(allocatedIP)
-			} else {
-				portPools.releaseByServer(serverID)
-			}
-		}
-
-		// Pre-start hook
-		if tmpl.Hooks.PreStart != "" {
-			fmt.Println("Calling pre_start hook:", tmpl.Hooks.PreStart)
-			resp, err := pulp.HTTP.Fetch(pulp.HTTPFetchRequest{
-				Method: "GET",
-				URL:    tmpl.Hooks.PreStart,
-			})
-			if err != nil {
-				fmt.Println("Hook error:", err)
-				releaseResources()
-				c.JSON(500, pulpgin.H{"error": "hook failed: " + err.Error()})
-				return
-			}
-			if resp.Status < 200 || resp.Status >= 300 {
-				fmt.Printf("Hook returned status %d\n", resp.Status)
-				releaseResources()
-				c.JSON(500, pulpgin.H{"error": fmt.Sprintf("hook returned %d", resp.Status)})
-				return
-			}
-			var hookResp struct {
-				Env map[string]string `json:"env"`
-			}
-			if err := json.Unmarshal(resp.Body, &hookResp); err != nil {
-				fmt.Println("Hook response decode error:", err)
-				releaseResources()
-				c.JSON(500, pulpgin.H{"error": "hook response decode failed: " + err.Error()})
-				return
-			}
-			fmt.Println("Hook returned env vars:", hookResp.Env)
-			for k, v := range hookResp.Env {
-				container.Environment[k] = v
-			}
-		} else {
-			fmt.Println("No pre_start hook defined")
-		}
-
-		// Resource overrides + caller env merge — single source of truth
-		// at pulp-cell/resources.Apply (8 unit tests guard precedence:
-		// YAML → legacy MemoryLimit/CPULimit → new MaxRam/MaxCpu → caller
-		// env wins; JVM heap = MaxRamMb - 1536 when JvmHeapMb=0).
-		rc := resources.Container{
-			MemoryLimit: container.MemoryLimit,
-			CPULimit:    container.CPULimit,
-			MemorySwap:  container.MemorySwap,
-			Environment: container.Environment,
-		}
-		var override orchestration.ResourceOverride
-		if req.Resources != nil {
-			override = *req.Resources
-		}
-		resources.Apply(&rc, resources.Override{
-			MemoryLimit: override.MemoryLimit,
-			CPULimit:    override.CPULimit,
-			MaxCpuCores: override.MaxCpuCores,
-			MaxRamMb:    override.MaxRamMb,
-			JvmHeapMb:   override.JvmHeapMb,
-		}, req.Env)
-		container.MemoryLimit = rc.MemoryLimit
-		container.CPULimit = rc.CPULimit
-		container.MemorySwap = rc.MemorySwap
-		container.Environment = rc.Environment
-
-		if err := capacity.tryAllocate(serverID, container.CPULimit, container.MemoryLimit); err != nil {
-			releaseResources()
-			c.JSON(503, pulpgin.H{"error": err.Error()})
-			return
-		}
-
-		fmt.Println("Final environment:", container.Environment)
-
-		container.Name = serverID
-		server, existing, err := createWithSpeculativeResources(
-			serverID,
-			containerToCreateRequest(container),
-			docker.Get,
-			docker.Create,
-			func() {
-				capacity.release(serverID)
-				releaseResources()
-			},
-		)
+		expiresAt, err := leaseExpiry(req.TTLSeconds, req.ExpiresAt, time.Now())
 		if err != nil {
-			c.JSON(500, pulpgin.H{"error": err.Error()})
+			c.JSON(400, pulpgin.H{"error": err.Error()})
 			return
 		}
-		if existing {
-			server := orchestrationResponseServer(*server, serverID, cfg.ExternalHost)
-			c.JSON(201, toOrchestrationServer(server))
+		server, perr := prov.create(req)
+		if perr != nil {
+			writeProvisionError(c, perr)
 			return
 		}
-
-		capacity.commit(serverID, server.ID)
-		if allocatedIP != "" {
-			ipp.reKey(serverID, server.ID)
-		} else {
-			portPools.reKey(serverID, server.ID)
+		if expiresAt > 0 {
+			leases.grant(server.ID, server.Name, expiresAt)
 		}
-
-		server.Name = serverID
-		if server.Ports == nil {
-			server.Ports = map[string]int{}
+		if tmpl, ok := prov.templates[req.Template]; ok {
+			idle.track(server.ID, server.Name, idleSuspendMinutes(tmpl, req.IdleSuspendMinutes))
 		}
-		if len(container.Ports) > 0 {
-			for _, p := range container.Ports {
-				portKey := p.Name
-				if portKey == "" {
-					portKey = fmt.Sprintf("%d", p.Container)
-				}
-				if _, ok := server.Ports[portKey]; !ok {
-					server.Ports[portKey] = p.Host
-				}
-			}
-		} else {
-			portKey := fmt.Sprintf("%d", allocatedPort)
-			if _, ok := server.Ports[portKey]; !ok {
-				server.Ports[portKey] = allocatedPort
-			}
-		}
-
-		responseServer := orchestrationResponseServer(*server, serverID, cfg.ExternalHost)
-		c.JSON(201, toOrchestrationServer(responseServer))
+		c.JSON(201, toOrchestrationServer(server))
 	})
 
 	orch.DELETE("/servers/:id", func(c *pulpgin.Context) {
-		id := c.Param("id")
+		id := prov.containerFor(c.Param("id"))
 
 		capacity.release(id)
+		quotas.release(id)
+		forget(id)
 
-		if c.Query("keep_ports") != "1" {
-			portPools.releaseByServer(id)
-			ipp.releaseByServer(id)
-		} else if newID := c.Query("server_id"); newID != "" {
-			portPools.reKey(id, newID)
-			ipp.reKey(id, newID)
-		}
+		prov.releasePorts(id, c.Query("keep_ports") == "1", c.Query("server_id"))
 
 		if err := docker.Destroy(id); err != nil {
 			// Idempotent destroy: if the container is already gone (or
@@ -826,6 +746,12 @@ func bootstrap(configBytes []byte) error {
 	registerFleetLifecycleRoutes(orch)
 	registerFleetObservationRoutes(orch)
 	registerFleetExecV2Route(orch)
+	registerWarmPoolRoutes(orch, warm)
+	registerLeaseRoutes(orch, leases)
+	registerIdleRoutes(orch, idle)
+	registerQuotaRoutes(orch, quotas)
+	registerReservationRoutes(orch, prov.reservations)
+	registerAdmissionRoutes(orch, prov)
 
 	orch.POST("/servers/:id/exec", func(c *pulpgin.Context) {
 		id := c.Param("id")
@@ -833,6 +759,7 @@ func bootstrap(configBytes []byte) error {
 			c.JSON(400, pulpgin.H{"error": "invalid container identity"})
 			return
 		}
+		id = prov.containerFor(id)
 		req, err := decodeLegacyExecRequest(c)
 		if err != nil {
 			c.JSON(400, pulpgin.H{"error": err.Error()})
@@ -851,7 +778,7 @@ func bootstrap(configBytes []byte) error {
 	})
 
 	orch.GET("/servers/:id/logs", func(c *pulpgin.Context) {
-		id := c.Param("id")
+		id := prov.containerFor(c.Param("id"))
 		tail := 200
 		if t := c.Query("tail"); t != "" {
 			if n, err := strconv.Atoi(t); err == nil && n > 0 {
@@ -874,7 +801,7 @@ func bootstrap(configBytes []byte) error {
 	})
 
 	orch.GET("/servers/:id/stats", func(c *pulpgin.Context) {
-		id := c.Param("id")
+		id := prov.containerFor(c.Param("id"))
 		stats, err := docker.Stats(id)
 		if err != nil {
 			if isDockerNotFound(err) {
@@ -1201,20 +1128,28 @@ func bootstrap(configBytes []byte) error {
 	// Auth-gated template reload (native cmd/server M7). Mutates in-memory
 	// templates and re-scans disk, so it sits behind the service token.
 	admin.POST("/reload-templates", func(c *pulpgin.Context) {
-		fresh, err := loadTemplates(cfg.TemplateFiles)
+		fresh, raw, err := loadTemplates(cfg.TemplateFiles)
 		if err != nil {
 			log.Printf("[Reload] Failed to reload templates: %v", err)
 			c.JSON(500, pulpgin.H{"error": err.Error()})
 			return
 		}
+		// A dry run reports what the reload would change in the catalog
+		// and leaves both the catalog and the loaded templates alone.
+		if c.Query("dry_run") == "true" {
+			writeTemplateCatalogDiff(c, fresh)
+			return
+		}
 		if err := synchronizeTemplateCatalog(fresh); err != nil {
 			log.Printf("[Reload] Failed to synchronize template catalog: %v", err)
 			c.JSON(500, pulpgin.H{"error": err.Error()})
 			return
 		}
-		templates = fresh
-		log.Printf("[Reload] Reloaded %d templates", len(templates))
-		c.JSON(200, pulpgin.H{"reloaded": len(templates)})
+		prov.templates = fresh
+		prov.rawTemplates = raw
+		warm.refill()
+		log.Printf("[Reload] Reloaded %d templates", len(fresh))
+		c.JSON(200, pulpgin.H{"reloaded": len(fresh)})
 	})
 
 	admin.POST("/build-image", func(c *pulpgin.Context) {
@@ -1268,6 +1203,12 @@ func bootstrap(configBytes []byte) error {
 		c.JSON(202, pulpgin.H{"building": true})
 	})
 
+	registerAllocationRoutes(admin, ledger)
+	registerDriftRoutes(admin, drift)
+	registerGCRoutes(admin, gc)
+	registerAdoptRoutes(admin, prov)
+	registerTemplateCatalogRoutes(admin, prov, warm)
+
 	admin.GET("/build-status", func(c *pulpgin.Context) {
 		status, err := docker.GetBuildStatus()
 		if err != nil {
@@ -1302,7 +1243,14 @@ func bootstrap(configBytes []byte) error {
 	//      last cursor and fan them out over the SSE route. Events are
 	//      polled (not pushed) because WASM can't hold a long-lived
 	//      Docker events connection — the host buffers them for us.
-	//   2. Forward the event itself to the pulpgin engine so HTTP and
+	//   2. Sweep server leases that lapsed, suspend servers that sat empty
+	//      past their idle policy, and release unconsumed capacity
+	//      reservations (throttled; see leases.go, idle.go, reservations.go).
+	//   3. Reconcile the allocators against docker.List every 30s, releasing
+	//      leaks and adopting unknown containers (reconcile.go), then persist
+	//      the allocation ledger if it changed (allocations.go). Every 10
+	//      minutes, age GC candidates (gc.go); nothing is removed here.
+	//   4. Forward the event itself to the pulpgin engine so HTTP and
 	//      WS traffic gets dispatched normally.
 	//
 	// Everything runs on the single cell goroutine, which is why the
@@ -1343,11 +1291,18 @@ func bootstrap(configBytes []byte) error {
 				}
 			}
 		}
+		now := time.Now()
+		leases.sweep(now)
+		idle.check(now)
+		prov.reservations.sweep(now)
+		drift.step(now)
+		ledger.store(now)
+		gc.step(now)
 		return r.Dispatch(ev)
 	})
 
 	fmt.Printf("[bananagine] ready — %d templates, cpu_budget=%.1f mem_budget=%.1f\n",
-		len(templates), cfg.CPUBudget, cfg.MemBudget)
+		len(prov.templates), cfg.CPUBudget, cfg.MemBudget)
 	return nil
 }
 
@@ -0,0 +1,107 @@
+package main
+
+import "strconv"
+
+// legacyCrossplayOption switches the bedrock platform on for templates that
+// declare no config.extensions.
+const legacyCrossplayOption = "_CROSSPLAY"
+
+// filterPlatformPorts drops ports the selected engine does not serve.
+//
+// A game template can declare ports for several player platforms (minecraft:
+// java + bedrock), but a server only exposes the platforms its engine
+// supports. Without this, every minecraft server got a bedrock port even for
+// a Java-only engine with crossplay off — a dead Geyser port (mc-f7613,
+// 2026-07-20).
+//
+// The active platforms are the ENGINE's Platforms plus those of every
+// extension the caller switched on. A port tagged with platforms is kept when
+// any of them is active; an untagged port whose name is a platform some engine
+// declares is treated as tagged with that name; any other port is always
+// kept. An unknown or absent engine keeps every port (safe back-compat for
+// games without a platform model, and for callers that don't send ENGINE).
+func filterPlatformPorts(tmpl Template, container *ContainerSpec, env map[string]string) {
+	if len(container.Ports) == 0 {
+		return
+	}
+	active := activePlatforms(tmpl.Config, env)
+	if len(active) == 0 {
+		return
+	}
+	declared := map[string]bool{}
+	for _, e := range tmpl.Config.Engines {
+		for _, platform := range e.Platforms {
+			declared[platform] = true
+		}
+	}
+	kept := container.Ports[:0]
+	for _, p := range container.Ports {
+		if servesActivePlatform(p, declared, active) {
+			kept = append(kept, p)
+		}
+	}
+	container.Ports = kept
+}
+
+// activePlatforms resolves env["ENGINE"] against the schema. It is empty when
+// the engine is unknown or declares no platforms.
+func activePlatforms(schema ConfigSchema, env map[string]string) map[string]bool {
+	engine := env["ENGINE"]
+	active := map[string]bool{}
+	for _, e := range schema.Engines {
+		if e.Value == engine {
+			for _, platform := range e.Platforms {
+				active[platform] = true
+			}
+			break
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	if len(schema.Extensions) == 0 {
+		// Templates written before extensions existed rely on the built-in
+		// Geyser toggle.
+		if on, _ := strconv.ParseBool(env[legacyCrossplayOption]); on {
+			active["bedrock"] = true
+		}
+		return active
+	}
+	for _, ext := range schema.Extensions {
+		if on, _ := strconv.ParseBool(env[ext.Option]); !on || !extensionApplies(ext, engine) {
+			continue
+		}
+		for _, platform := range ext.Platforms {
+			active[platform] = true
+		}
+	}
+	return active
+}
+
+func extensionApplies(ext PlatformExtension, engine string) bool {
+	if len(ext.Engines) == 0 {
+		return true
+	}
+	for _, e := range ext.Engines {
+		if e == engine {
+			return true
+		}
+	}
+	return false
+}
+
+func servesActivePlatform(p PortSpec, declared, active map[string]bool) bool {
+	platforms := p.Platforms
+	if len(platforms) == 0 {
+		if !declared[p.Name] {
+			return true
+		}
+		platforms = []string{p.Name}
+	}
+	for _, platform := range platforms {
+		if active[platform] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func portNames(ports []PortSpec) []string {
+	var names []string
+	for _, p := range ports {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestFilterPlatformPorts(t *testing.T) {
+	tmpl := Template{
+		Container: ContainerSpec{Ports: []PortSpec{
+			{Name: "game", Platforms: []string{"pc"}},
+			{Name: "console", Platforms: []string{"xbox", "ps"}},
+			{Name: "bedrock"},
+			{Name: "rcon"},
+		}},
+		Config: ConfigSchema{
+			Engines: []EngineOption{
+				{Value: "vanilla", Platforms: []string{"pc"}},
+				{Value: "console", Platforms: []string{"xbox"}},
+				{Value: "legacy", Platforms: []string{"bedrock"}},
+			},
+			Extensions: []PlatformExtension{
+				{Option: "CROSSPLAY", Engines: []string{"vanilla"}, Platforms: []string{"ps", "bedrock"}},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{"ENGINE": "vanilla"}, []string{"game", "rcon"}},
+		{map[string]string{"ENGINE": "vanilla", "CROSSPLAY": "true"}, []string{"game", "console", "bedrock", "rcon"}},
+		{map[string]string{"ENGINE": "console", "CROSSPLAY": "true"}, []string{"console", "rcon"}},
+		{map[string]string{"ENGINE": "legacy"}, []string{"bedrock", "rcon"}},
+		{map[string]string{"ENGINE": "unknown"}, []string{"game", "console", "bedrock", "rcon"}},
+		{nil, []string{"game", "console", "bedrock", "rcon"}},
+	} {
+		container := deepCopyContainer(tmpl.Container)
+		filterPlatformPorts(tmpl, &container, tc.env)
+		if got := portNames(container.Ports); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("env %v: ports %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestFilterPlatformPortsLegacyCrossplay(t *testing.T) {
+	tmpl := Template{
+		Container: ContainerSpec{Ports: []PortSpec{{Name: "java"}, {Name: "bedrock"}}},
+		Config: ConfigSchema{Engines: []EngineOption{
+			{Value: "paper", Platforms: []string{"java"}},
+			{Value: "bedrock", Platforms: []string{"bedrock"}},
+		}},
+	}
+	for _, tc := range []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{"ENGINE": "paper"}, []string{"java"}},
+		{map[string]string{"ENGINE": "paper", "_CROSSPLAY": "true"}, []string{"java", "bedrock"}},
+	} {
+		container := deepCopyContainer(tmpl.Container)
+		filterPlatformPorts(tmpl, &container, tc.env)
+		if got := portNames(container.Ports); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("env %v: ports %v, want %v", tc.env, got, tc.want)
+		}
+	}
+
+	// A template that declares extensions owns the option names.
+	tmpl.Config.Extensions = []PlatformExtension{{Option: "GEYSER", Platforms: []string{"bedrock"}}}
+	container := deepCopyContainer(tmpl.Container)
+	filterPlatformPorts(tmpl, &container, map[string]string{"ENGINE": "paper", "_CROSSPLAY": "true"})
+	if got := portNames(container.Ports); !reflect.DeepEqual(got, []string{"java"}) {
+		t.Errorf("declared extensions: ports %v, want [java]", got)
+	}
+}
@@ -1,12 +1,29 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"time"
+)
 
 // Single-threaded WASM — no mutex needed. See capacity.go for details.
 type portPool struct {
 	start     int
 	end       int
 	allocated map[int]string // port -> server ID
+	since     map[int]int64  // port -> unix second it was leased
+	// sticky remembers the server ID each port was allocated for; a
+	// committed create re-keys allocated to the container ID but not this.
+	sticky map[int]string
+	// released remembers who last held each freed port and when, so that
+	// server gets it back and nobody else reuses it within cooldown.
+	released map[int]portRelease
+	cooldown time.Duration
+}
+
+type portRelease struct {
+	serverID string
+	at       int64
 }
 
 func newPortPool(start, end int) *portPool {
@@ -14,24 +31,100 @@ func newPortPool(start, end int) *portPool {
 		start:     start,
 		end:       end,
 		allocated: make(map[int]string),
+		since:     make(map[int]int64),
+		sticky:    make(map[int]string),
+		released:  make(map[int]portRelease),
 	}
 }
 
+// allocate leases serverID the port it last held if that is free. Otherwise
+// it picks the lowest port nobody has released, then the port released
+// longest ago, so a freed port stays available to its server for as long as
+// possible. Ports released by another server within cooldown are skipped.
 func (p *portPool) allocate(serverID string) (int, error) {
+	now := time.Now().Unix()
+	best, cooling := 0, 0
 	for port := p.start; port <= p.end; port++ {
-		if _, used := p.allocated[port]; !used {
-			p.allocated[port] = serverID
-			return port, nil
+		if _, used := p.allocated[port]; used {
+			continue
+		}
+		release, wasReleased := p.released[port]
+		if wasReleased && release.serverID == serverID {
+			best = port
+			break
+		}
+		if wasReleased && p.coolingDown(release, now) {
+			cooling++
+			continue
+		}
+		if best == 0 {
+			best = port
+			continue
+		}
+		if prior, priorReleased := p.released[best]; priorReleased && (!wasReleased || release.at < prior.at) {
+			best = port
 		}
 	}
-	return 0, fmt.Errorf("no ports available in range %d-%d", p.start, p.end)
+	if best == 0 {
+		if cooling > 0 {
+			return 0, fmt.Errorf("no ports available in range %d-%d (%d cooling down)", p.start, p.end, cooling)
+		}
+		return 0, fmt.Errorf("no ports available in range %d-%d", p.start, p.end)
+	}
+	p.allocated[best] = serverID
+	p.since[best] = now
+	p.sticky[best] = serverID
+	delete(p.released, best)
+	return best, nil
 }
 
+// claim leases serverID a specific port. The port must be in range, free and
+// not cooling down for another server.
+func (p *portPool) claim(port int, serverID string) error {
+	if err := p.claimable(port, serverID); err != nil {
+		return err
+	}
+	p.allocated[port] = serverID
+	p.since[port] = time.Now().Unix()
+	p.sticky[port] = serverID
+	delete(p.released, port)
+	return nil
+}
+
+// claimable reports why claim(port, serverID) would fail, without leasing.
+func (p *portPool) claimable(port int, serverID string) error {
+	if !p.contains(port) {
+		return fmt.Errorf("port %d is outside range %s", port, p.name())
+	}
+	if _, used := p.allocated[port]; used {
+		return fmt.Errorf("port %d is already allocated", port)
+	}
+	if release, ok := p.released[port]; ok && release.serverID != serverID && p.coolingDown(release, time.Now().Unix()) {
+		return fmt.Errorf("port %d was released recently and is cooling down", port)
+	}
+	return nil
+}
+
+func (p *portPool) coolingDown(release portRelease, now int64) bool {
+	return p.cooldown > 0 && now-release.at < int64(p.cooldown/time.Second)
+}
+
+// releaseByServer frees serverID's ports. Releasing a committed server's port
+// records it for stickiness and cooldown; undoing a speculative allocation
+// (still keyed by the server ID it was made for) or a reservation hold does
+// not.
 func (p *portPool) releaseByServer(serverID string) {
+	now := time.Now().Unix()
 	for port, id := range p.allocated {
-		if id == serverID {
-			delete(p.allocated, port)
+		if id != serverID {
+			continue
 		}
+		if owner := p.sticky[port]; owner != id {
+			p.released[port] = portRelease{serverID: owner, at: now}
+		}
+		delete(p.allocated, port)
+		delete(p.since, port)
+		delete(p.sticky, port)
 	}
 }
 
@@ -44,16 +137,53 @@ func (p *portPool) reKey(oldID, newID string) {
 }
 
 func (p *portPool) reserve(port int, serverID string) {
+	p.restore(port, serverID, time.Now().Unix())
+}
+
+// restore leases port to serverID as of since, for leases rebuilt from the
+// persisted allocation ledger.
+func (p *portPool) restore(port int, serverID string, since int64) {
 	p.allocated[port] = serverID
+	p.since[port] = since
 }
 
 func (p *portPool) contains(port int) bool {
 	return port >= p.start && port <= p.end
 }
 
+// available counts free ports in the range that are not cooling down.
+// Reconciliation may reserve ports outside every range into the fallback
+// pool, so only in-range ones count.
+func (p *portPool) available() int {
+	used := 0
+	for port := range p.allocated {
+		if p.contains(port) {
+			used++
+		}
+	}
+	now := time.Now().Unix()
+	for port, release := range p.released {
+		if _, leased := p.allocated[port]; !leased && p.contains(port) && p.coolingDown(release, now) {
+			used++
+		}
+	}
+	return p.end - p.start + 1 - used
+}
+
+// remember records serverID as the owner of every port key holds, for
+// containers reconciliation adopted rather than created.
+func (p *portPool) remember(key, serverID string) {
+	for port, id := range p.allocated {
+		if id == key {
+			p.sticky[port] = serverID
+		}
+	}
+}
+
 type portPoolSet struct {
 	fallback *portPool
 	pools    map[string]*portPool
+	cooldown time.Duration
 }
 
 func newPortPoolSet(fallback *portPool) *portPoolSet {
@@ -63,6 +193,14 @@ func newPortPoolSet(fallback *portPool) *portPoolSet {
 	}
 }
 
+// setCooldown sets how long a released port is withheld from other servers.
+func (ps *portPoolSet) setCooldown(cooldown time.Duration) {
+	ps.cooldown = cooldown
+	for _, p := range ps.all() {
+		p.cooldown = cooldown
+	}
+}
+
 func parseRange(r string) (int, int, error) {
 	var start, end int
 	_, err := fmt.Sscanf(r, "%d-%d", &start, &end)
@@ -81,6 +219,7 @@ func (ps *portPoolSet) getOrCreate(rangeStr string) (*portPool, error) {
 		return nil, err
 	}
 	p := newPortPool(start, end)
+	p.cooldown = ps.cooldown
 	ps.pools[rangeStr] = p
 	return p, nil
 }
@@ -96,6 +235,51 @@ func (ps *portPoolSet) allocate(rangeStr, serverID string) (int, error) {
 	return pool.allocate(serverID)
 }
 
+// claim leases a specific port from rangeStr's pool.
+func (ps *portPoolSet) claim(rangeStr string, port int, serverID string) error {
+	if rangeStr == "" {
+		return ps.fallback.claim(port, serverID)
+	}
+	pool, err := ps.getOrCreate(rangeStr)
+	if err != nil {
+		return err
+	}
+	return pool.claim(port, serverID)
+}
+
+// claimable checks a claim without leasing or creating rangeStr's pool.
+func (ps *portPoolSet) claimable(rangeStr string, port int, serverID string) error {
+	if rangeStr == "" {
+		return ps.fallback.claimable(port, serverID)
+	}
+	if pool, ok := ps.pools[rangeStr]; ok {
+		return pool.claimable(port, serverID)
+	}
+	start, end, err := parseRange(rangeStr)
+	if err != nil {
+		return err
+	}
+	if port < start || port > end {
+		return fmt.Errorf("port %d is outside range %s", port, rangeStr)
+	}
+	return nil
+}
+
+// available counts free ports in rangeStr without creating its pool.
+func (ps *portPoolSet) available(rangeStr string) (int, error) {
+	if rangeStr == "" {
+		return ps.fallback.available(), nil
+	}
+	if p, ok := ps.pools[rangeStr]; ok {
+		return p.available(), nil
+	}
+	start, end, err := parseRange(rangeStr)
+	if err != nil {
+		return 0, err
+	}
+	return end - start + 1, nil
+}
+
 func (ps *portPoolSet) releaseByServer(serverID string) {
 	ps.fallback.releaseByServer(serverID)
 	for _, p := range ps.pools {
@@ -111,11 +295,69 @@ func (ps *portPoolSet) reKey(oldID, newID string) {
 }
 
 func (ps *portPoolSet) reserve(port int, serverID string) {
+	ps.poolFor(port).reserve(port, serverID)
+}
+
+func (ps *portPoolSet) remember(key, serverID string) {
+	for _, p := range ps.all() {
+		p.remember(key, serverID)
+	}
+}
+
+// poolFor returns the range pool containing port, or the fallback pool.
+func (ps *portPoolSet) poolFor(port int) *portPool {
 	for _, p := range ps.pools {
 		if p.contains(port) {
-			p.reserve(port, serverID)
-			return
+			return p
+		}
+	}
+	return ps.fallback
+}
+
+func (p *portPool) name() string {
+	return fmt.Sprintf("%d-%d", p.start, p.end)
+}
+
+// all returns the fallback pool followed by every range pool, sorted.
+func (ps *portPoolSet) all() []*portPool {
+	pools := []*portPool{ps.fallback}
+	ranges := make([]string, 0, len(ps.pools))
+	for r := range ps.pools {
+		ranges = append(ranges, r)
+	}
+	sort.Strings(ranges)
+	for _, r := range ranges {
+		pools = append(pools, ps.pools[r])
+	}
+	return pools
+}
+
+// portHistory is one persisted release record; see portPool.released.
+type portHistory struct {
+	Port       int    `json:"port"`
+	ServerID   string `json:"server_id,omitempty"`
+	ReleasedAt int64  `json:"released_at"`
+}
+
+// history lists every release record, sorted by port.
+func (ps *portPoolSet) history() []portHistory {
+	var result []portHistory
+	for _, p := range ps.all() {
+		for port, release := range p.released {
+			result = append(result, portHistory{Port: port, ServerID: release.serverID, ReleasedAt: release.at})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Port < result[j].Port })
+	return result
+}
+
+// restoreHistory reloads release records for ports nobody holds.
+func (ps *portPoolSet) restoreHistory(entries []portHistory) {
+	for _, entry := range entries {
+		p := ps.poolFor(entry.Port)
+		if _, leased := p.allocated[entry.Port]; leased {
+			continue
 		}
+		p.released[entry.Port] = portRelease{serverID: entry.ServerID, at: entry.ReleasedAt}
 	}
-	ps.fallback.reserve(port, serverID)
 }
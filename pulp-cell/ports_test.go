@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPortPoolStickyAllocation(t *testing.T) {
+	p := newPortPool(7000, 7003)
+	first, _ := p.allocate("lobby")
+	second, _ := p.allocate("arena")
+	p.reKey("lobby", "c1")
+	p.reKey("arena", "c2")
+	if first != 7000 || second != 7001 {
+		t.Fatalf("allocated %d, %d", first, second)
+	}
+
+	p.releaseByServer("c1")
+	// A new server prefers a never-released port over lobby's old one.
+	if port, _ := p.allocate("other"); port != 7002 {
+		t.Fatalf("other got %d, want 7002", port)
+	}
+	if port, _ := p.allocate("lobby"); port != 7000 {
+		t.Fatalf("lobby got %d back, want 7000", port)
+	}
+
+	// Undoing a speculative allocation is not a release.
+	p.allocate("failed")
+	p.releaseByServer("failed")
+	if _, recorded := p.released[7003]; recorded {
+		t.Fatal("speculative release recorded in history")
+	}
+}
+
+func TestPortPoolReuseCooldown(t *testing.T) {
+	ps := newPortPoolSet(newPortPool(7000, 7001))
+	ps.setCooldown(time.Hour)
+	ps.allocate("", "lobby")
+	ps.allocate("", "arena")
+	ps.reKey("lobby", "c1")
+	ps.releaseByServer("c1")
+
+	if free, _ := ps.available(""); free != 0 {
+		t.Fatalf("available = %d, want 0 while 7000 cools down", free)
+	}
+	if _, err := ps.allocate("", "other"); err == nil {
+		t.Fatal("cooling port handed to another server")
+	}
+	if port, err := ps.allocate("", "lobby"); err != nil || port != 7000 {
+		t.Fatalf("lobby = %d, %v; want its port back during cooldown", port, err)
+	}
+
+	ps.releaseByServer("lobby")
+	history := ps.history()
+	if len(history) != 0 {
+		t.Fatalf("history = %+v after speculative release", history)
+	}
+	ps.restoreHistory([]portHistory{{Port: 7000, ServerID: "lobby", ReleasedAt: time.Now().Add(-2 * time.Hour).Unix()}})
+	if port, err := ps.allocate("", "other"); err != nil || port != 7000 {
+		t.Fatalf("other = %d, %v; want 7000 after cooldown", port, err)
+	}
+}
+
+func TestPortPoolClaim(t *testing.T) {
+	ps := newPortPoolSet(newPortPool(7000, 7003))
+	ps.setCooldown(time.Hour)
+	if err := ps.claim("", 7002, "lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := ps.allocate("", "arena"); port != 7000 {
+		t.Fatalf("arena got %d, want 7000", port)
+	}
+	if err := ps.claim("", 7002, "other"); err == nil {
+		t.Fatal("claimed an allocated port")
+	}
+	if err := ps.claimable("", 7010, "other"); err == nil {
+		t.Fatal("claimable accepted a port outside the range")
+	}
+
+	ps.reKey("lobby", "c1")
+	ps.releaseByServer("c1")
+	if err := ps.claim("", 7002, "other"); err == nil {
+		t.Fatal("claimed a port cooling down for another server")
+	}
+	if err := ps.claim("", 7002, "lobby"); err != nil {
+		t.Fatalf("lobby reclaiming its own port: %v", err)
+	}
+}
+
+func TestValidateRequestedPorts(t *testing.T) {
+	container := ContainerSpec{Ports: []PortSpec{
+		{Name: "java", Range: "7000-7099"},
+		{Name: "query", Range: "7000-7199"},
+	}}
+	if err := validateRequestedPorts(container, map[string]int{"java": 7005}); err != nil {
+		t.Fatal(err)
+	}
+	for name, requested := range map[string]map[string]int{
+		"unknown name":   {"rcon": 7005},
+		"outside range":  {"java": 7105},
+		"invalid port":   {"java": 70000},
+		"duplicate port": {"java": 7005, "query": 7005},
+	} {
+		if err := validateRequestedPorts(container, requested); err == nil {
+			t.Errorf("%s: accepted %v", name, requested)
+		}
+	}
+	container.Network = "overlay"
+	if err := validateRequestedPorts(container, map[string]int{"java": 7005}); err == nil {
+		t.Error("accepted requested ports for an overlay template")
+	}
+}
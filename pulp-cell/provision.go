@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp"
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+	"github.com/bananalabs-oss/bananagine/templatecatalog"
+
+	"bananagine-cell/resources"
+)
+
+// provisioner owns the create path shared by POST /orchestration/servers and
+// the warm pool. It holds the same single-threaded allocators bootstrap builds;
+// see capacity.go for why none of them are locked.
+type provisioner struct {
+	cfg       appConfig
+	templates map[string]Template
+	// bindings maps a logical ServerID to a container whose Docker name is
+	// something else, such as a claimed warm-pool standby.
+	bindings map[string]string
+	// labels holds the ownership labels of adopted containers, keyed by
+	// container ID; see labeled.
+	labels       map[string]map[string]string
+	adoptions    map[string]adoption
+	capacity     *capacityTracker
+	ipp          *ipPoolSet
+	portPools    *portPoolSet
+	quotas       *quotaTracker
+	reservations *reservationBook
+	// rendered records the template files rendered for each container; see
+	// files.go.
+	rendered map[string]renderedFiles
+	// rawTemplates holds the templates as written, abstract ones included,
+	// before extends and mixins are resolved into templates.
+	rawTemplates map[string]Template
+	// kept holds the allocator keys whose port and IP holds a DELETE with
+	// keep_ports kept on purpose; see releasePorts.
+	kept map[string]struct{}
+}
+
+// provisionError carries the HTTP status the legacy create handler answered
+// with for each failure, so every caller of the create path reports the same
+// wire shape.
+type provisionError struct {
+	status  int
+	message string
+	// fields lists per-field errors for an invalid settings request.
+	fields []templatecatalog.FieldError
+}
+
+func (e *provisionError) Error() string {
+	return e.message
+}
+
+func provisionFailure(status int, err error) *provisionError {
+	return &provisionError{status: status, message: err.Error()}
+}
+
+// containerFor resolves a server ID bound by a warm-pool claim or an adoption
+// to its container. Any other ID is returned unchanged.
+func (p *provisioner) containerFor(id string) string {
+	if containerID, bound := p.bindings[id]; bound {
+		return containerID
+	}
+	return id
+}
+
+// create instantiates req.Template and returns the public projection of the
+// resulting container. A retry with an existing ServerID returns the existing
+// container without replaying any provisioning side effect.
+func (p *provisioner) create(req createServerRequest) (docker.Server, *provisionError) {
+	// Evolution retries provision requests with the same ServerID. Resolve
+	// that exact Docker name before looking up templates, allocating a port
+	// or IP, calling hooks, or consuming capacity. A retry therefore has the
+	// same successful create shape but never replays provisioning side effects.
+	if req.ServerID != "" {
+		if containerID, bound := p.bindings[req.ServerID]; bound {
+			existing, err := docker.Get(containerID)
+			if err != nil && !isDockerNotFound(err) {
+				return docker.Server{}, provisionFailure(500, err)
+			}
+			if err == nil && existing != nil {
+				return orchestrationResponseServer(*existing, req.ServerID, p.cfg.ExternalHost), nil
+			}
+		}
+		existing, found, err := existingServerForRequestedID(req.ServerID, docker.Get)
+		if err != nil {
+			return docker.Server{}, provisionFailure(500, err)
+		}
+		if found {
+			return orchestrationResponseServer(*existing, req.ServerID, p.cfg.ExternalHost), nil
+		}
+	}
+
+	if req.ReservationID == "" {
+		return p.provision(req)
+	}
+	// Consuming a reservation frees its hold just before provisioning, so a
+	// server no larger than the reservation always fits. A failed create puts
+	// the hold back for the caller's retry.
+	reservation, ok := p.reservations.take(req.ReservationID, time.Now())
+	if !ok {
+		return docker.Server{}, &provisionError{status: 404, message: "reservation not found or expired"}
+	}
+	server, perr := p.provision(req)
+	if perr != nil {
+		p.reservations.restore(reservation)
+	}
+	return server, perr
+}
+
+// provision runs the side-effecting half of create once retries and
+// reservations are resolved.
+func (p *provisioner) provision(req createServerRequest) (docker.Server, *provisionError) {
+	tmpl, ok := p.templates[req.Template]
+	if !ok {
+		return docker.Server{}, &provisionError{status: 404, message: "template not found"}
+	}
+	if req.Owner != "" && !validFleetIdentity(req.Owner) {
+		return docker.Server{}, &provisionError{status: 400, message: "invalid owner"}
+	}
+	if req.Tier != "" && !validFleetIdentity(req.Tier) {
+		return docker.Server{}, &provisionError{status: 400, message: "invalid tier"}
+	}
+	env, fields := validateCallerSettings(tmpl, req.Env)
+	if len(fields) > 0 {
+		return docker.Server{}, invalidSettings(fields)
+	}
+	req.Env = env
+	settings, _, err := translateSettings(tmpl.Config, req.Env)
+	if err != nil {
+		return docker.Server{}, provisionFailure(400, err)
+	}
+
+	container := deepCopyContainer(tmpl.Container)
+	filterPlatformPorts(tmpl, &container, req.Env)
+	if err := validateRequestedPorts(container, req.Ports); err != nil {
+		return docker.Server{}, provisionFailure(400, err)
+	}
+
+	serverID := req.ServerID
+	if serverID == "" {
+		serverID = fmt.Sprintf("%s-%d", req.Template, time.Now().UnixNano())
+	}
+
+	if container.Environment == nil {
+		container.Environment = make(map[string]string)
+	}
+	for k, v := range tmpl.Server {
+		container.Environment[k] = v
+	}
+
+	var allocatedIPs []string
+	var allocatedPort int
+
+	if container.Network != "" {
+		ips, err := p.ipp.allocate(container.Network, serverID)
+		if err != nil {
+			return docker.Server{}, provisionFailure(503, err)
+		}
+		allocatedIPs = ips
+		for _, addr := range ips {
+			if net.ParseIP(addr).To4() != nil {
+				container.IP = addr
+			} else {
+				container.IPv6 = addr
+			}
+		}
+		ip := ips[0]
+
+		allocatedPort = 5520
+		if len(container.Ports) > 0 {
+			allocatedPort = container.Ports[0].Container
+		}
+
+		container.Environment["SERVER_HOST"] = ip
+		for _, p := range container.Ports {
+			if p.Name != "" {
+				container.Environment["PORT_"+strings.ToUpper(p.Name)] = fmt.Sprintf("%d", p.Container)
+			}
+		}
+
+		fmt.Printf("Overlay mode: %s -> %s:%d\n", serverID, ip, allocatedPort)
+	} else {
+		var allocatedPorts []int
+		for i := range container.Ports {
+			spec := container.Ports[i]
+			var port int
+			if requested, ok := req.Ports[spec.Name]; ok && spec.Name != "" {
+				if err := p.portPools.claim(spec.Range, requested, serverID); err != nil {
+					p.portPools.releaseByServer(serverID)
+					return docker.Server{}, provisionFailure(409, err)
+				}
+				port = requested
+			} else {
+				var err error
+				port, err = p.portPools.allocate(spec.Range, serverID)
+				if err != nil {
+					p.portPools.releaseByServer(serverID)
+					return docker.Server{}, provisionFailure(503, err)
+				}
+			}
+			allocatedPorts = append(allocatedPorts, port)
+			container.Ports[i].Host = port
+			container.Ports[i].Container = port
+		}
+		if len(allocatedPorts) == 0 {
+			port, err := p.portPools.allocate("", serverID)
+			if err != nil {
+				return docker.Server{}, provisionFailure(503, err)
+			}
+			allocatedPorts = append(allocatedPorts, port)
+		}
+		allocatedPort = allocatedPorts[0]
+
+		container.Environment["SERVER_HOST"] = "0.0.0.0"
+		for i, p := range container.Ports {
+			if p.Name != "" {
+				container.Environment["PORT_"+strings.ToUpper(p.Name)] = fmt.Sprintf("%d", allocatedPorts[i])
+			}
+		}
+
+		fmt.Printf("Host mode: %s -> 0.0.0.0:%d\n", serverID, allocatedPort)
+	}
+
+	container.Environment["SERVER_PORT"] = fmt.Sprintf("%d", allocatedPort)
+	container.Environment["SERVER_ID"] = serverID
+
+	releaseResources := func() {
+		if len(allocatedIPs) > 0 {
+			p.ipp.release(container.Network, allocatedIPs...)
+		} else {
+			p.portPools.releaseByServer(serverID)
+		}
+	}
+
+	vars := interpolationVars(req.Template, container.Ports, container.Environment, req.Env, p.cfg.ExternalHost)
+	if err := interpolateContainer(&container, vars); err != nil {
+		releaseResources()
+		return docker.Server{}, provisionFailure(400, err)
+	}
+	hookURL, err := interpolateURL(tmpl.Hooks.PreStart, vars)
+	if err != nil {
+		releaseResources()
+		return docker.Server{}, provisionFailure(400, fmt.Errorf("pre_start hook: %w", err))
+	}
+	propertiesPath, err := settings.applyCreate(&container)
+	if err != nil {
+		releaseResources()
+		return docker.Server{}, provisionFailure(500, fmt.Errorf("apply settings: %w", err))
+	}
+	renders, err := renderFiles(tmpl.Files, container.Volumes, vars)
+	if err != nil {
+		releaseResources()
+		return docker.Server{}, provisionFailure(400, err)
+	}
+
+	// Pre-start hook
+	if hookURL != "" {
+		fmt.Println("Calling pre_start hook:", hookURL)
+		resp, err := pulp.HTTP.Fetch(pulp.HTTPFetchRequest{
+			Method: "GET",
+			URL:    hookURL,
+		})
+		if err != nil {
+			fmt.Println("Hook error:", err)
+			releaseResources()
+			return docker.Server{}, &provisionError{status: 500, message: "hook failed: " + err.Error()}
+		}
+		if resp.Status < 200 || resp.Status >= 300 {
+			fmt.Printf("Hook returned status %d\n", resp.Status)
+			releaseResources()
+			return docker.Server{}, &provisionError{status: 500, message: fmt.Sprintf("hook returned %d", resp.Status)}
+		}
+		var hookResp struct {
+			Env map[string]string `json:"env"`
+		}
+		if err := json.Unmarshal(resp.Body, &hookResp); err != nil {
+			fmt.Println("Hook response decode error:", err)
+			releaseResources()
+			return docker.Server{}, &provisionError{status: 500, message: "hook response decode failed: " + err.Error()}
+		}
+		fmt.Println("Hook returned env vars:", hookResp.Env)
+		for k, v := range hookResp.Env {
+			container.Environment[k] = v
+		}
+	} else {
+		fmt.Println("No pre_start hook defined")
+	}
+
+	applyResourceOverride(&container, req)
+
+	if err := p.quotas.admit(req.Owner, container.CPULimit, container.MemoryLimit); err != nil {
+		releaseResources()
+		return docker.Server{}, provisionFailure(403, err)
+	}
+	if err := p.capacity.tryAllocate(serverID, container.CPULimit, container.MemoryLimit); err != nil {
+		releaseResources()
+		return docker.Server{}, provisionFailure(503, err)
+	}
+
+	fmt.Println("Final environment:", container.Environment)
+
+	container.Name = serverID
+	createReq := containerToCreateRequest(container)
+	createReq.Labels = serverLabels(req.Template, serverID, req.Owner, req.Tier)
+	if container.Network != "" {
+		// Pools are per network and subnets may overlap, so reconciliation
+		// needs the network to find the pool an address came from.
+		createReq.Labels[networkLabel] = container.Network
+	}
+	if container.IPv6 != "" {
+		// docker.Server reports one address, so reconciliation reads the
+		// second family of a dual-stack server from this label.
+		createReq.Labels[ipv6Label] = container.IPv6
+	}
+
+	// Properties and rendered files land in the server's volume, so they are
+	// only written once the hook, quota and capacity checks have passed, and
+	// never into the volume of a server a racing retry already created. A
+	// create that fails or loses that race afterwards puts the volume back.
+	if req.ServerID != "" {
+		if existing, found, err := existingServerForRequestedID(serverID, docker.Get); err != nil || found {
+			p.capacity.release(serverID)
+			releaseResources()
+			if err != nil {
+				return docker.Server{}, provisionFailure(500, err)
+			}
+			return orchestrationResponseServer(*existing, serverID, p.cfg.ExternalHost), nil
+		}
+	}
+	paths := []string{propertiesPath}
+	for _, render := range renders {
+		paths = append(paths, render.hostPath)
+	}
+	written, err := snapshotFiles(paths...)
+	if err != nil {
+		p.capacity.release(serverID)
+		releaseResources()
+		return docker.Server{}, provisionFailure(500, fmt.Errorf("read volume files: %w", err))
+	}
+	if err := settings.writeCreateProperties(propertiesPath); err != nil {
+		written.restore()
+		p.capacity.release(serverID)
+		releaseResources()
+		return docker.Server{}, provisionFailure(500, fmt.Errorf("apply settings: %w", err))
+	}
+	renderedFiles, err := writeRenderedFiles(renders, nil)
+	if err != nil {
+		written.restore()
+		p.capacity.release(serverID)
+		releaseResources()
+		return docker.Server{}, provisionFailure(500, fmt.Errorf("render files: %w", err))
+	}
+	server, existing, err := createWithSpeculativeResources(
+		serverID,
+		createReq,
+		docker.Get,
+		docker.Create,
+		func() {
+			p.capacity.release(serverID)
+			releaseResources()
+		},
+	)
+	if err != nil || existing {
+		written.restore()
+	}
+	if err != nil {
+		return docker.Server{}, provisionFailure(500, err)
+	}
+	if existing {
+		return orchestrationResponseServer(*server, serverID, p.cfg.ExternalHost), nil
+	}
+
+	p.capacity.commit(serverID, server.ID)
+	if len(renderedFiles) > 0 {
+		p.recordRenderedFiles(server.ID, vars, renderedFiles)
+	}
+	p.quotas.charge(server.ID, req.Owner, serverID, container.CPULimit, container.MemoryLimit)
+	if len(allocatedIPs) > 0 {
+		p.ipp.reKey(serverID, server.ID)
+	} else {
+		p.portPools.reKey(serverID, server.ID)
+	}
+
+	server.Name = serverID
+	if server.Ports == nil {
+		server.Ports = map[string]int{}
+	}
+	if len(container.Ports) > 0 {
+		for _, p := range container.Ports {
+			portKey := p.Name
+			if portKey == "" {
+				portKey = fmt.Sprintf("%d", p.Container)
+			}
+			if _, ok := server.Ports[portKey]; !ok {
+				server.Ports[portKey] = p.Host
+			}
+		}
+	} else {
+		portKey := fmt.Sprintf("%d", allocatedPort)
+		if _, ok := server.Ports[portKey]; !ok {
+			server.Ports[portKey] = allocatedPort
+		}
+	}
+
+	return orchestrationResponseServer(*server, serverID, p.cfg.ExternalHost), nil
+}
+
+// release returns every allocator lease held by a container. Each release is
+// idempotent, so callers may invoke it for a container that was never tracked.
+func (p *provisioner) release(containerID string) {
+	p.capacity.release(containerID)
+	p.quotas.release(containerID)
+	p.portPools.releaseByServer(containerID)
+	p.ipp.releaseByServer(containerID)
+}
+
+// releasePorts settles the port and IP holds of a deleted container. With
+// keep the holds stay, re-keyed to serverID when one is given so the next
+// create of that server gets them back. A kept hold has no container by
+// design, so it is recorded for drift reconciliation to leave alone.
+func (p *provisioner) releasePorts(containerID string, keep bool, serverID string) {
+	if !keep {
+		delete(p.kept, containerID)
+		p.portPools.releaseByServer(containerID)
+		p.ipp.releaseByServer(containerID)
+		return
+	}
+	key := containerID
+	if serverID != "" {
+		p.portPools.reKey(containerID, serverID)
+		p.ipp.reKey(containerID, serverID)
+		delete(p.kept, containerID)
+		key = serverID
+	}
+	p.kept[key] = struct{}{}
+}
+
+// validateRequestedPorts checks a create's requested host ports against the
+// container's named ports and their ranges. Availability is checked when the
+// ports are claimed.
+func validateRequestedPorts(container ContainerSpec, requested map[string]int) error {
+	if len(requested) == 0 {
+		return nil
+	}
+	if container.Network != "" {
+		return fmt.Errorf("ports cannot be requested for an overlay template")
+	}
+	byName := make(map[string]PortSpec, len(container.Ports))
+	for _, spec := range container.Ports {
+		if spec.Name != "" {
+			byName[spec.Name] = spec
+		}
+	}
+	claimed := make(map[int]string, len(requested))
+	for name, port := range requested {
+		spec, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("template has no port named %q", name)
+		}
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("requested port %s=%d is not a valid port", name, port)
+		}
+		if spec.Range != "" {
+			start, end, err := parseRange(spec.Range)
+			if err != nil {
+				return err
+			}
+			if port < start || port > end {
+				return fmt.Errorf("requested port %s=%d is outside its range %s", name, port, spec.Range)
+			}
+		}
+		if other, dup := claimed[port]; dup {
+			return fmt.Errorf("requested port %d is given to both %s and %s", port, other, name)
+		}
+		claimed[port] = name
+	}
+	return nil
+}
+
+// applyResourceOverride resolves the container's limits and merges the caller's
+// env. Admission calls it too, so both size a server identically.
+func applyResourceOverride(container *ContainerSpec, req createServerRequest) {
+	// Resource overrides + caller env merge — single source of truth
+	// at pulp-cell/resources.Apply (8 unit tests guard precedence:
+	// YAML → legacy MemoryLimit/CPULimit → new MaxRam/MaxCpu → caller
+	// env wins; JVM heap = MaxRamMb - 1536 when JvmHeapMb=0).
+	rc := resources.Container{
+		MemoryLimit: container.MemoryLimit,
+		CPULimit:    container.CPULimit,
+		MemorySwap:  container.MemorySwap,
+		Environment: container.Environment,
+	}
+	var override orchestration.ResourceOverride
+	if req.Resources != nil {
+		override = *req.Resources
+	}
+	resources.Apply(&rc, resources.Override{
+		MemoryLimit: override.MemoryLimit,
+		CPULimit:    override.CPULimit,
+		MaxCpuCores: override.MaxCpuCores,
+		MaxRamMb:    override.MaxRamMb,
+		JvmHeapMb:   override.JvmHeapMb,
+	}, req.Env)
+	container.MemoryLimit = rc.MemoryLimit
+	container.CPULimit = rc.CPULimit
+	container.MemorySwap = rc.MemorySwap
+	container.Environment = rc.Environment
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp"
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+	"gopkg.in/yaml.v3"
+)
+
+// quotaSpec is one owner's entry in the operator's quotas file.
+type quotaSpec struct {
+	MaxServers       int     `yaml:"max_servers"`
+	MaxCPU           float64 `yaml:"max_cpu"`
+	MaxMemoryGiB     float64 `yaml:"max_memory_gib"`
+	MaxWorldsDiskGiB float64 `yaml:"max_worlds_disk_gib"`
+}
+
+// quotaTracker enforces per-owner limits on top of the node-wide
+// capacityTracker budget. Servers without an owner are only subject to the
+// node budget; owners missing from the quotas file are tracked but unlimited.
+type quotaTracker struct {
+	quotas     map[string]orchestration.TenantQuota
+	servers    map[string]quotaCharge // container ID -> charge
+	worldsRoot string
+}
+
+type quotaCharge struct {
+	owner  string
+	name   string // Docker name; the server's world lives at worldsRoot/name
+	cpu    float64
+	memGiB float64
+}
+
+func newQuotaTracker(quotas map[string]orchestration.TenantQuota, worldsRoot string) *quotaTracker {
+	if quotas == nil {
+		quotas = make(map[string]orchestration.TenantQuota)
+	}
+	return &quotaTracker{
+		quotas:     quotas,
+		servers:    make(map[string]quotaCharge),
+		worldsRoot: worldsRoot,
+	}
+}
+
+// loadQuotas reads the YAML quotas file named by the `quotas` config key, a
+// map of owner to limits. An empty path configures no quotas.
+func loadQuotas(path string) (map[string]orchestration.TenantQuota, error) {
+	quotas := make(map[string]orchestration.TenantQuota)
+	if path == "" {
+		return quotas, nil
+	}
+	data, err := pulp.FS.Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("read quotas %s: %w", path, err)
+	}
+	var specs map[string]quotaSpec
+	if err := yaml.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("parse quotas %s: %w", path, err)
+	}
+	for owner, spec := range specs {
+		if !validFleetIdentity(owner) {
+			return nil, fmt.Errorf("quotas %s: invalid owner %q", path, owner)
+		}
+		if spec.MaxServers < 0 || spec.MaxCPU < 0 || spec.MaxMemoryGiB < 0 || spec.MaxWorldsDiskGiB < 0 {
+			return nil, fmt.Errorf("quotas %s: owner %q has a negative limit", path, owner)
+		}
+		quotas[owner] = orchestration.TenantQuota{
+			MaxServers:       spec.MaxServers,
+			MaxCPU:           spec.MaxCPU,
+			MaxMemoryGiB:     spec.MaxMemoryGiB,
+			MaxWorldsDiskGiB: spec.MaxWorldsDiskGiB,
+		}
+	}
+	return quotas, nil
+}
+
+// observe charges an already-running container to the owner on its label.
+func (q *quotaTracker) observe(server docker.Server) {
+	owner := server.Labels[ownerLabel]
+	if owner == "" {
+		return
+	}
+	q.charge(server.ID, owner, strings.TrimPrefix(server.Name, "/"), server.CPULimit, server.MemoryLimit)
+}
+
+// admit reports whether owner may start one more server of the given size.
+func (q *quotaTracker) admit(owner string, cpuLimit float64, memLimitBytes int64) error {
+	if owner == "" {
+		return nil
+	}
+	quota, limited := q.quotas[owner]
+	if !limited {
+		return nil
+	}
+	usage := q.usage(owner, quota.MaxWorldsDiskGiB > 0)
+	if reason := quotaViolation(quota, usage, cpuLimit, bytesToGiB(memLimitBytes)); reason != "" {
+		return fmt.Errorf("quota exceeded for owner %s: %s", owner, reason)
+	}
+	return nil
+}
+
+func (q *quotaTracker) charge(containerID, owner, name string, cpuLimit float64, memLimitBytes int64) {
+	if owner == "" {
+		return
+	}
+	q.servers[containerID] = quotaCharge{owner: owner, name: name, cpu: cpuLimit, memGiB: bytesToGiB(memLimitBytes)}
+}
+
+func (q *quotaTracker) release(containerID string) {
+	delete(q.servers, containerID)
+}
+
+// usage sums owner's charges. Worlds disk is walked only when asked because
+// it reads every file's metadata under each of the owner's world directories.
+func (q *quotaTracker) usage(owner string, withDisk bool) orchestration.TenantUsage {
+	var usage orchestration.TenantUsage
+	var diskBytes int64
+	for _, charge := range q.servers {
+		if charge.owner != owner {
+			continue
+		}
+		usage.Servers++
+		usage.CPU += charge.cpu
+		usage.MemoryGiB += charge.memGiB
+		if withDisk {
+			diskBytes += worldDiskBytes(q.worldsRoot + "/" + charge.name)
+		}
+	}
+	usage.WorldsDiskGiB = bytesToGiB(diskBytes)
+	return usage
+}
+
+// status lists every owner with a quota or a running server, sorted by owner.
+func (q *quotaTracker) status() []orchestration.QuotaStatus {
+	seen := make(map[string]struct{}, len(q.quotas))
+	for owner := range q.quotas {
+		seen[owner] = struct{}{}
+	}
+	for _, charge := range q.servers {
+		seen[charge.owner] = struct{}{}
+	}
+	owners := make([]string, 0, len(seen))
+	for owner := range seen {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	result := make([]orchestration.QuotaStatus, 0, len(owners))
+	for _, owner := range owners {
+		result = append(result, orchestration.QuotaStatus{
+			Owner: owner,
+			Quota: q.quotas[owner],
+			Usage: q.usage(owner, true),
+		})
+	}
+	return result
+}
+
+// quotaViolation returns why adding a server of cpu/memGiB to usage would break
+// quota, or "" when it fits. Worlds disk cannot be predicted for a new server,
+// so it only blocks an owner that is already at or over its limit.
+func quotaViolation(quota orchestration.TenantQuota, usage orchestration.TenantUsage, cpu, memGiB float64) string {
+	switch {
+	case quota.MaxServers > 0 && usage.Servers+1 > quota.MaxServers:
+		return fmt.Sprintf("server count (%d + 1 > %d)", usage.Servers, quota.MaxServers)
+	case quota.MaxCPU > 0 && usage.CPU+cpu > quota.MaxCPU:
+		return fmt.Sprintf("CPU (%.2f + %.2f > %.2f)", usage.CPU, cpu, quota.MaxCPU)
+	case quota.MaxMemoryGiB > 0 && usage.MemoryGiB+memGiB > quota.MaxMemoryGiB:
+		return fmt.Sprintf("memory (%.2f + %.2f > %.2f GiB)", usage.MemoryGiB, memGiB, quota.MaxMemoryGiB)
+	case quota.MaxWorldsDiskGiB > 0 && usage.WorldsDiskGiB >= quota.MaxWorldsDiskGiB:
+		return fmt.Sprintf("worlds disk (%.2f >= %.2f GiB)", usage.WorldsDiskGiB, quota.MaxWorldsDiskGiB)
+	default:
+		return ""
+	}
+}
+
+// worldDiskBytes sums regular file sizes under dir, skipping symlinks like
+// walkAndZip. A missing or unreadable directory counts as empty.
+func worldDiskBytes(dir string) int64 {
+	entries, err := pulp.FS.List(dir)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, e := range entries {
+		fullPath := dir + "/" + e.Name
+		info, err := pulp.FS.Stat(fullPath)
+		if err != nil || os.FileMode(info.Mode)&os.ModeSymlink != 0 {
+			continue
+		}
+		if e.IsDir {
+			total += worldDiskBytes(fullPath)
+			continue
+		}
+		total += info.Size
+	}
+	return total
+}
+
+func bytesToGiB(n int64) float64 {
+	return float64(n) / (1024 * 1024 * 1024)
+}
+
+func registerQuotaRoutes(group *pulpgin.RouterGroup, quotas *quotaTracker) {
+	group.GET("/quotas", func(c *pulpgin.Context) {
+		c.JSON(200, quotas.status())
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+func TestQuotaViolation(t *testing.T) {
+	quota := orchestration.TenantQuota{MaxServers: 3, MaxCPU: 4, MaxMemoryGiB: 8, MaxWorldsDiskGiB: 10}
+	tests := []struct {
+		name  string
+		quota orchestration.TenantQuota
+		usage orchestration.TenantUsage
+		cpu   float64
+		mem   float64
+		want  string
+	}{
+		{name: "fits", quota: quota, usage: orchestration.TenantUsage{Servers: 2, CPU: 2, MemoryGiB: 4, WorldsDiskGiB: 9}, cpu: 2, mem: 4},
+		{name: "server count", quota: quota, usage: orchestration.TenantUsage{Servers: 3}, want: "server count"},
+		{name: "cpu", quota: quota, usage: orchestration.TenantUsage{CPU: 3}, cpu: 1.5, want: "CPU"},
+		{name: "memory", quota: quota, usage: orchestration.TenantUsage{MemoryGiB: 6}, mem: 4, want: "memory"},
+		{name: "worlds disk at limit", quota: quota, usage: orchestration.TenantUsage{WorldsDiskGiB: 10}, want: "worlds disk"},
+		{name: "zero limits are unlimited", usage: orchestration.TenantUsage{Servers: 100, CPU: 100}, cpu: 100, mem: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quotaViolation(tt.quota, tt.usage, tt.cpu, tt.mem)
+			if tt.want == "" {
+				if got != "" {
+					t.Fatalf("quotaViolation = %q, want fit", got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("quotaViolation = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaTrackerAdmitAndStatus(t *testing.T) {
+	q := newQuotaTracker(map[string]orchestration.TenantQuota{
+		"acme": {MaxServers: 1},
+	}, "worlds")
+	const gib = 1024 * 1024 * 1024
+
+	if err := q.admit("acme", 1, gib); err != nil {
+		t.Fatalf("first admit: %v", err)
+	}
+	q.charge("c1", "acme", "mc-1", 1, gib)
+	q.charge("c2", "other", "mc-2", 2, 2*gib)
+	if err := q.admit("acme", 1, gib); err == nil || !strings.Contains(err.Error(), "quota exceeded for owner acme") {
+		t.Fatalf("second admit error = %v", err)
+	}
+	if err := q.admit("other", 64, 64*gib); err != nil {
+		t.Fatalf("owner without quota rejected: %v", err)
+	}
+	if err := q.admit("", 64, 64*gib); err != nil {
+		t.Fatalf("ownerless server rejected: %v", err)
+	}
+
+	status := q.status()
+	if len(status) != 2 || status[0].Owner != "acme" || status[1].Owner != "other" {
+		t.Fatalf("status owners = %+v", status)
+	}
+	if status[0].Usage.Servers != 1 || status[1].Usage.MemoryGiB != 2 {
+		t.Fatalf("status usage = %+v", status)
+	}
+
+	q.release("c1")
+	if err := q.admit("acme", 1, gib); err != nil {
+		t.Fatalf("admit after release: %v", err)
+	}
+}
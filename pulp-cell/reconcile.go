@@ -0,0 +1,258 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+)
+
+const driftReconcileInterval = 30 * time.Second
+
+// adopt leases a container's ports, IP and quota charge to it, and its CPU and
+// memory too when it is managed and holdCapacity is set. Ports and addresses
+// are held for every container, managed or not, so a create never collides
+// with them. It returns the kinds of lease it newly took.
+func (p *provisioner) adopt(server docker.Server, holdCapacity bool) ([]string, error) {
+	server = p.labeled(server)
+	var adopted []string
+	for _, port := range server.Ports {
+		if _, held := p.portPools.poolFor(port).allocated[port]; !held {
+			p.portPools.reserve(port, server.ID)
+			adopted = appendKind(adopted, "port")
+		}
+	}
+	if serverID := server.Labels[serverIDLabel]; serverID != "" {
+		p.portPools.remember(server.ID, serverID)
+	}
+	for _, ip := range serverIPs(server) {
+		network, labeled := server.Labels[networkLabel]
+		if !labeled {
+			network = p.ipp.locate(ip)
+		}
+		pool := p.ipp.forNetwork(network)
+		if _, held := pool.holder(ip); !held {
+			pool.reserve(ip, server.ID)
+			adopted = appendKind(adopted, "ip")
+		}
+	}
+	if _, charged := p.quotas.servers[server.ID]; !charged && server.Labels[ownerLabel] != "" {
+		p.quotas.observe(server)
+		adopted = append(adopted, "quota")
+	}
+	if !holdCapacity || !p.manages(server) || (server.CPULimit <= 0 && server.MemoryLimit <= 0) {
+		return adopted, nil
+	}
+	if _, held := p.capacity.containers[server.ID]; held {
+		return adopted, nil
+	}
+	if err := p.capacity.tryAllocate(server.ID, server.CPULimit, server.MemoryLimit); err != nil {
+		return adopted, err
+	}
+	return append(adopted, "capacity"), nil
+}
+
+// holders returns every allocator key except reservation holds, sorted.
+func (p *provisioner) holders() []string {
+	seen := make(map[string]struct{})
+	for id := range p.capacity.containers {
+		seen[id] = struct{}{}
+	}
+	for _, pool := range p.ipp.all() {
+		for _, id := range pool.allocated {
+			seen[id] = struct{}{}
+		}
+	}
+	for _, pool := range p.portPools.all() {
+		for _, id := range pool.allocated {
+			seen[id] = struct{}{}
+		}
+	}
+	for id := range p.quotas.servers {
+		seen[id] = struct{}{}
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		if !strings.HasPrefix(id, reservationKey("")) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// leakedHolders lists the allocator keys held for no live container that
+// gone confirms removed. Holds a DELETE kept with keep_ports are not leaks;
+// a kept key nobody holds any more, because a create took its ports back,
+// stops being tracked.
+func (p *provisioner) leakedHolders(live map[string]struct{}, gone func(string) bool) []string {
+	holders := p.holders()
+	held := make(map[string]struct{}, len(holders))
+	var leaked []string
+	for _, key := range holders {
+		held[key] = struct{}{}
+		if _, ok := live[key]; ok {
+			continue
+		}
+		if _, kept := p.kept[key]; kept {
+			continue
+		}
+		// docker.List can omit stopped containers; only a confirmed
+		// not-found frees the lease.
+		if gone(key) {
+			leaked = append(leaked, key)
+		}
+	}
+	for key := range p.kept {
+		if _, ok := held[key]; !ok {
+			delete(p.kept, key)
+		}
+	}
+	return leaked
+}
+
+// heldKinds lists the kinds of lease key holds.
+func (p *provisioner) heldKinds(key string) []string {
+	var kinds []string
+	if _, held := p.capacity.containers[key]; held {
+		kinds = append(kinds, "capacity")
+	}
+	for _, pool := range p.ipp.all() {
+		for _, id := range pool.allocated {
+			if id == key {
+				kinds = appendKind(kinds, "ip")
+			}
+		}
+	}
+	for _, pool := range p.portPools.all() {
+		for _, id := range pool.allocated {
+			if id == key {
+				kinds = appendKind(kinds, "port")
+			}
+		}
+	}
+	if _, held := p.quotas.servers[key]; held {
+		kinds = append(kinds, "quota")
+	}
+	return kinds
+}
+
+// serverIPs lists a container's addresses: the one Docker reports and, for a
+// dual-stack server, the IPv6 address recorded on its label.
+func serverIPs(server docker.Server) []string {
+	var ips []string
+	if server.IP != "" {
+		ips = append(ips, server.IP)
+	}
+	if ip := server.Labels[ipv6Label]; ip != "" && ip != server.IP {
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+func appendKind(kinds []string, kind string) []string {
+	for _, k := range kinds {
+		if k == kind {
+			return kinds
+		}
+	}
+	return append(kinds, kind)
+}
+
+// driftReconciler repeats bootstrap's reconciliation from the step loop. It
+// releases leases whose container was removed outside Bananagine and adopts
+// containers the allocators do not know about.
+type driftReconciler struct {
+	prov *provisioner
+	idle *idleTracker
+	// forget drops a removed container from the cell's other trackers.
+	forget  func(containerID string)
+	nextRun time.Time
+	last    driftReport
+}
+
+type driftReport struct {
+	RanAt    int64        `json:"ran_at"`
+	Released []driftEntry `json:"released"`
+	Adopted  []driftEntry `json:"adopted"`
+	Error    string       `json:"error,omitempty"`
+}
+
+type driftEntry struct {
+	ContainerID string   `json:"container_id"`
+	Name        string   `json:"name,omitempty"`
+	Leases      []string `json:"leases"`
+}
+
+func newDriftReconciler(prov *provisioner, idle *idleTracker, forget func(string), now time.Time) *driftReconciler {
+	return &driftReconciler{
+		prov:    prov,
+		idle:    idle,
+		forget:  forget,
+		nextRun: now.Add(driftReconcileInterval),
+	}
+}
+
+// step runs a pass once driftReconcileInterval has elapsed.
+func (d *driftReconciler) step(now time.Time) {
+	if now.Before(d.nextRun) {
+		return
+	}
+	d.run(now)
+}
+
+func (d *driftReconciler) run(now time.Time) driftReport {
+	d.nextRun = now.Add(driftReconcileInterval)
+	report := driftReport{RanAt: now.Unix(), Released: []driftEntry{}, Adopted: []driftEntry{}}
+	servers, err := docker.List(nil)
+	if err != nil {
+		report.Error = err.Error()
+		d.last = report
+		return report
+	}
+
+	live := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		live[server.ID] = struct{}{}
+		// Idle-suspended servers gave their capacity back on purpose.
+		kinds, err := d.prov.adopt(server, !d.idle.suspended(server.ID))
+		if err != nil {
+			log.Printf("[Reconcile] capacity allocation failed for %s: %v", server.ID, err)
+		}
+		if len(kinds) == 0 {
+			continue
+		}
+		entry := driftEntry{ContainerID: server.ID, Name: strings.TrimPrefix(server.Name, "/"), Leases: kinds}
+		report.Adopted = append(report.Adopted, entry)
+		log.Printf("[Reconcile] adopted %s (%s)", entry.Name, strings.Join(kinds, ", "))
+		emitOrchestrationEvent(wireEvent{ContainerID: entry.ContainerID, Name: entry.Name, Action: "drift-adopted", Time: now.UnixNano()})
+	}
+
+	for _, key := range d.prov.leakedHolders(live, containerGone) {
+		entry := driftEntry{ContainerID: key, Leases: d.prov.heldKinds(key)}
+		d.prov.release(key)
+		d.forget(key)
+		report.Released = append(report.Released, entry)
+		log.Printf("[Reconcile] released leaked leases of %s (%s)", key, strings.Join(entry.Leases, ", "))
+		emitOrchestrationEvent(wireEvent{ContainerID: key, Action: "drift-released", Time: now.UnixNano()})
+	}
+	d.last = report
+	return report
+}
+
+func registerDriftRoutes(group *pulpgin.RouterGroup, drift *driftReconciler) {
+	group.GET("/drift", func(c *pulpgin.Context) {
+		c.JSON(200, drift.last)
+	})
+	group.POST("/reconcile", func(c *pulpgin.Context) {
+		report := drift.run(time.Now())
+		if report.Error != "" {
+			c.JSON(500, pulpgin.H{"error": report.Error})
+			return
+		}
+		c.JSON(200, report)
+	})
+}
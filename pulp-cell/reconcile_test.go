@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+func newTestReconcileProvisioner() *provisioner {
+	capacity := newCapacityTracker(8, 16)
+	portPools := newPortPoolSet(newPortPool(6000, 6009))
+	return &provisioner{
+		templates:    map[string]Template{"mc": {Name: "mc"}},
+		bindings:     make(map[string]string),
+		capacity:     capacity,
+		ipp:          mustIPPoolSet("10.0.0.1", "10.0.0.4"),
+		portPools:    portPools,
+		quotas:       newQuotaTracker(map[string]orchestration.TenantQuota{}, "worlds"),
+		reservations: newReservationBook(capacity, portPools),
+		kept:         make(map[string]struct{}),
+	}
+}
+
+func TestAdoptLeasesManagedAndUnmanagedContainers(t *testing.T) {
+	p := newTestReconcileProvisioner()
+	managed := docker.Server{
+		ID:          "c1",
+		Name:        "/mc-1",
+		IP:          "10.0.0.2",
+		Ports:       map[string]int{"java": 6001, "query": 6002},
+		CPULimit:    2,
+		MemoryLimit: 2 * gib,
+		Labels:      map[string]string{ownerLabel: "acme"},
+	}
+	kinds, err := p.adopt(managed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"port", "ip", "quota", "capacity"}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+	if again, _ := p.adopt(managed, true); len(again) != 0 {
+		t.Fatalf("second adopt took %v", again)
+	}
+
+	unmanaged := docker.Server{ID: "c2", Name: "/other", Ports: map[string]int{"http": 6003}, CPULimit: 1}
+	if kinds, _ := p.adopt(unmanaged, true); !reflect.DeepEqual(kinds, []string{"port"}) {
+		t.Fatalf("unmanaged kinds = %v", kinds)
+	}
+
+	suspended := docker.Server{ID: "c3", Name: "/mc-3", CPULimit: 1}
+	if kinds, _ := p.adopt(suspended, false); len(kinds) != 0 {
+		t.Fatalf("suspended server took %v", kinds)
+	}
+}
+
+func TestHoldersAndHeldKinds(t *testing.T) {
+	p := newTestReconcileProvisioner()
+	if _, err := p.adopt(docker.Server{ID: "c1", Name: "/mc-1", Ports: map[string]int{"a": 6001, "b": 6002}, CPULimit: 1}, true); err != nil {
+		t.Fatal(err)
+	}
+	p.ipp.forNetwork("").reserve("10.0.0.3", "c2")
+	if _, perr := p.reservations.reserve(orchestration.ReservationRequest{CPULimit: 1}, time.Unix(100, 0)); perr != nil {
+		t.Fatal(perr.message)
+	}
+
+	if got := p.holders(); !reflect.DeepEqual(got, []string{"c1", "c2"}) {
+		t.Fatalf("holders = %v", got)
+	}
+	if got := p.heldKinds("c1"); !reflect.DeepEqual(got, []string{"capacity", "port"}) {
+		t.Fatalf("heldKinds(c1) = %v", got)
+	}
+	p.release("c1")
+	if got := p.heldKinds("c1"); len(got) != 0 {
+		t.Fatalf("heldKinds after release = %v", got)
+	}
+}
+
+func TestDriftLeavesKeptPortsAlone(t *testing.T) {
+	p := newTestReconcileProvisioner()
+	for _, id := range []string{"c1", "c2", "c3"} {
+		if _, err := p.portPools.allocate("", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// DELETE c1?keep_ports=1&server_id=mc-next, DELETE c2?keep_ports=1, and
+	// c3 removed outside Bananagine.
+	p.releasePorts("c1", true, "mc-next")
+	p.releasePorts("c2", true, "")
+	gone := func(string) bool { return true }
+
+	if leaked := p.leakedHolders(map[string]struct{}{}, gone); !reflect.DeepEqual(leaked, []string{"c3"}) {
+		t.Fatalf("leaked = %v, want [c3]", leaked)
+	}
+	if kinds := p.heldKinds("mc-next"); !reflect.DeepEqual(kinds, []string{"port"}) {
+		t.Fatalf("mc-next holds %v after drift", kinds)
+	}
+
+	// A create takes mc-next's port back under its new container.
+	p.portPools.reKey("mc-next", "c4")
+	p.leakedHolders(map[string]struct{}{"c4": {}}, gone)
+	if _, kept := p.kept["mc-next"]; kept {
+		t.Fatal("kept set still tracks a key nobody holds")
+	}
+	p.releasePorts("c2", false, "")
+	if _, kept := p.kept["c2"]; kept || len(p.heldKinds("c2")) != 0 {
+		t.Fatalf("plain delete left c2 kept=%v holds=%v", kept, p.heldKinds("c2"))
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+const (
+	defaultReservationTTL    = 5 * time.Minute
+	maxReservationTTL        = time.Hour
+	reservationSweepInterval = 5 * time.Second
+)
+
+// reservationBook holds capacity and ports for schedulers that pick a node
+// before the final server spec exists. A reservation is an ordinary
+// capacityTracker and portPoolSet entry keyed by reservationKey, so every
+// other create sees the budget as taken. Reservations are not persisted:
+// startup reconciliation rebuilds the allocators from running containers and
+// a restarted cell simply has none outstanding.
+type reservationBook struct {
+	capacity     *capacityTracker
+	portPools    *portPoolSet
+	reservations map[string]orchestration.Reservation
+	nextSweep    time.Time
+	// sequence keeps IDs issued within one clock tick distinct.
+	sequence uint64
+}
+
+func newReservationBook(capacity *capacityTracker, portPools *portPoolSet) *reservationBook {
+	return &reservationBook{
+		capacity:     capacity,
+		portPools:    portPools,
+		reservations: make(map[string]orchestration.Reservation),
+	}
+}
+
+func reservationKey(id string) string {
+	return "reservation:" + id
+}
+
+func (b *reservationBook) reserve(req orchestration.ReservationRequest, now time.Time) (orchestration.Reservation, *provisionError) {
+	ttl, err := reservationTTL(req.TTLSeconds)
+	if err != nil {
+		return orchestration.Reservation{}, provisionFailure(400, err)
+	}
+	if req.CPULimit < 0 || req.MemoryLimit < 0 {
+		return orchestration.Reservation{}, &provisionError{status: 400, message: "cpu_limit and memory_limit cannot be negative"}
+	}
+	if req.CPULimit == 0 && req.MemoryLimit == 0 && len(req.PortRanges) == 0 {
+		return orchestration.Reservation{}, &provisionError{status: 400, message: "reservation holds nothing"}
+	}
+	reservation := orchestration.Reservation{
+		ID:          b.nextID(now),
+		CPULimit:    req.CPULimit,
+		MemoryLimit: req.MemoryLimit,
+		PortRanges:  req.PortRanges,
+		ExpiresAt:   now.Add(ttl).Unix(),
+	}
+	ports, perr := b.hold(reservation)
+	if perr != nil {
+		return orchestration.Reservation{}, perr
+	}
+	reservation.Ports = ports
+	b.reservations[reservation.ID] = reservation
+	return reservation, nil
+}
+
+// nextID returns an ID no outstanding reservation has. Reservations are not
+// persisted, so the clock only has to keep IDs apart across restarts.
+func (b *reservationBook) nextID(now time.Time) string {
+	for {
+		b.sequence++
+		id := fmt.Sprintf("rsv-%d-%d", now.UnixNano(), b.sequence)
+		if _, taken := b.reservations[id]; !taken {
+			return id
+		}
+	}
+}
+
+// hold takes the reservation's capacity and ports, undoing both on failure.
+func (b *reservationBook) hold(reservation orchestration.Reservation) ([]int, *provisionError) {
+	key := reservationKey(reservation.ID)
+	if err := b.capacity.tryAllocate(key, reservation.CPULimit, reservation.MemoryLimit); err != nil {
+		return nil, provisionFailure(503, err)
+	}
+	ports := make([]int, 0, len(reservation.PortRanges))
+	for _, portRange := range reservation.PortRanges {
+		port, err := b.portPools.allocate(strings.TrimSpace(portRange), key)
+		if err != nil {
+			b.unhold(reservation.ID)
+			return nil, provisionFailure(503, err)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func (b *reservationBook) unhold(id string) {
+	key := reservationKey(id)
+	b.capacity.release(key)
+	b.portPools.releaseByServer(key)
+}
+
+// take removes a live reservation and frees what it held so the create that
+// consumes it allocates into exactly that room. Because the cell is
+// single-threaded, nothing else can claim the room before that create runs.
+func (b *reservationBook) take(id string, now time.Time) (orchestration.Reservation, bool) {
+	reservation, ok := b.reservations[id]
+	if !ok || reservation.ExpiresAt <= now.Unix() {
+		return orchestration.Reservation{}, false
+	}
+	delete(b.reservations, id)
+	b.unhold(id)
+	return reservation, true
+}
+
+// peek returns a live reservation without consuming it.
+func (b *reservationBook) peek(id string, now time.Time) (orchestration.Reservation, bool) {
+	reservation, ok := b.reservations[id]
+	if !ok || reservation.ExpiresAt <= now.Unix() {
+		return orchestration.Reservation{}, false
+	}
+	return reservation, true
+}
+
+// restore puts back a reservation whose consuming create failed, so the
+// caller can retry against the same hold until it expires.
+func (b *reservationBook) restore(reservation orchestration.Reservation) {
+	ports, perr := b.hold(reservation)
+	if perr != nil {
+		log.Printf("[Reservation] restore %s: %s", reservation.ID, perr.message)
+		return
+	}
+	reservation.Ports = ports
+	b.reservations[reservation.ID] = reservation
+}
+
+func (b *reservationBook) cancel(id string) bool {
+	if _, ok := b.reservations[id]; !ok {
+		return false
+	}
+	delete(b.reservations, id)
+	b.unhold(id)
+	return true
+}
+
+// sweep releases reservations that were never consumed. It runs from the step
+// loop, throttled to reservationSweepInterval.
+func (b *reservationBook) sweep(now time.Time) {
+	if now.Before(b.nextSweep) {
+		return
+	}
+	b.nextSweep = now.Add(reservationSweepInterval)
+	for id, reservation := range b.reservations {
+		if reservation.ExpiresAt <= now.Unix() {
+			b.cancel(id)
+			log.Printf("[Reservation] %s expired unconsumed", id)
+		}
+	}
+}
+
+func (b *reservationBook) list() []orchestration.Reservation {
+	result := make([]orchestration.Reservation, 0, len(b.reservations))
+	for _, reservation := range b.reservations {
+		result = append(result, reservation)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// reservationTTL defaults an unset TTL and caps long ones: a reservation is a
+// placement handshake, not a way to park capacity.
+func reservationTTL(ttlSeconds int64) (time.Duration, error) {
+	switch {
+	case ttlSeconds < 0:
+		return 0, fmt.Errorf("ttl_seconds cannot be negative")
+	case ttlSeconds == 0:
+		return defaultReservationTTL, nil
+	case ttlSeconds > int64(maxReservationTTL/time.Second):
+		return 0, fmt.Errorf("ttl_seconds cannot exceed %d", int64(maxReservationTTL/time.Second))
+	default:
+		return time.Duration(ttlSeconds) * time.Second, nil
+	}
+}
+
+func registerReservationRoutes(group *pulpgin.RouterGroup, book *reservationBook) {
+	group.GET("/reservations", func(c *pulpgin.Context) {
+		c.JSON(200, book.list())
+	})
+	group.POST("/reservations", func(c *pulpgin.Context) {
+		var req orchestration.ReservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		reservation, perr := book.reserve(req, time.Now())
+		if perr != nil {
+			c.JSON(perr.status, pulpgin.H{"error": perr.message})
+			return
+		}
+		c.JSON(201, reservation)
+	})
+	group.DELETE("/reservations/:id", func(c *pulpgin.Context) {
+		if !book.cancel(c.Param("id")) {
+			c.JSON(404, pulpgin.H{"error": "reservation not found"})
+			return
+		}
+		c.Status(204)
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+func newTestReservationBook() *reservationBook {
+	return newReservationBook(newCapacityTracker(4, 8), newPortPoolSet(newPortPool(6000, 6001)))
+}
+
+func TestReservationHoldsCapacityAndPorts(t *testing.T) {
+	book := newTestReservationBook()
+	now := time.Unix(1000, 0)
+	const gib = 1024 * 1024 * 1024
+
+	reservation, perr := book.reserve(orchestration.ReservationRequest{
+		CPULimit:    3,
+		MemoryLimit: 4 * gib,
+		PortRanges:  []string{"", ""},
+	}, now)
+	if perr != nil {
+		t.Fatalf("reserve: %s", perr.message)
+	}
+	if len(reservation.Ports) != 2 || reservation.ExpiresAt != now.Add(defaultReservationTTL).Unix() {
+		t.Fatalf("reservation = %+v", reservation)
+	}
+	if err := book.capacity.tryAllocate("other", 2, 0); err == nil {
+		t.Fatal("capacity held by the reservation was handed to another server")
+	}
+	if _, err := book.portPools.allocate("", "other"); err == nil {
+		t.Fatal("port held by the reservation was handed to another server")
+	}
+
+	taken, ok := book.take(reservation.ID, now)
+	if !ok || taken.ID != reservation.ID {
+		t.Fatalf("take = %+v, %v", taken, ok)
+	}
+	if err := book.capacity.tryAllocate("consumer", 3, 4*gib); err != nil {
+		t.Fatalf("consumer did not fit into the freed reservation: %v", err)
+	}
+	if _, ok := book.take(reservation.ID, now); ok {
+		t.Fatal("reservation consumed twice")
+	}
+}
+
+func TestReservationRestoreAndSweep(t *testing.T) {
+	book := newTestReservationBook()
+	now := time.Unix(1000, 0)
+
+	reservation, perr := book.reserve(orchestration.ReservationRequest{CPULimit: 4, TTLSeconds: 30}, now)
+	if perr != nil {
+		t.Fatalf("reserve: %s", perr.message)
+	}
+	taken, _ := book.take(reservation.ID, now)
+	book.restore(taken)
+	if len(book.list()) != 1 {
+		t.Fatalf("restored reservation missing: %+v", book.list())
+	}
+	if _, ok := book.take(reservation.ID, now.Add(30*time.Second)); ok {
+		t.Fatal("expired reservation was consumable")
+	}
+
+	book.sweep(now.Add(30 * time.Second))
+	if len(book.list()) != 0 {
+		t.Fatalf("expired reservation survived sweep: %+v", book.list())
+	}
+	if err := book.capacity.tryAllocate("after", 4, 0); err != nil {
+		t.Fatalf("sweep did not release capacity: %v", err)
+	}
+}
+
+func TestReservationTTL(t *testing.T) {
+	tests := []struct {
+		ttl     int64
+		want    time.Duration
+		wantErr bool
+	}{
+		{ttl: 0, want: defaultReservationTTL},
+		{ttl: 90, want: 90 * time.Second},
+		{ttl: 3600, want: time.Hour},
+		{ttl: 3601, wantErr: true},
+		{ttl: -1, wantErr: true},
+		{ttl: 1 << 62, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := reservationTTL(tt.ttl)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("reservationTTL(%d) = %v, %v; want %v, err=%v", tt.ttl, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReservationIDsAreUniqueWithinATick(t *testing.T) {
+	book := newTestReservationBook()
+	now := time.Unix(1000, 0)
+	first, perr := book.reserve(orchestration.ReservationRequest{CPULimit: 1}, now)
+	if perr != nil {
+		t.Fatalf("reserve: %s", perr.message)
+	}
+	second, perr := book.reserve(orchestration.ReservationRequest{CPULimit: 1}, now)
+	if perr != nil {
+		t.Fatalf("reserve: %s", perr.message)
+	}
+	if first.ID == second.ID || len(book.list()) != 2 {
+		t.Fatalf("reservations = %+v", book.list())
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/templatecatalog"
+)
+
+// Caller settings travel in the create env under these prefixes, the same
+// keys the fleet reconfigure action accepts.
+const (
+	settingEnvPrefix  = "FLEET_SETTING_"
+	gameRuleEnvPrefix = "FLEET_GAMERULE_"
+)
+
+// settingsSchema is the validation view of a template's config schema. It
+// matches what templatecatalog decodes from the published ConfigJSON, so the
+// cell and the catalog validate identically.
+func settingsSchema(schema ConfigSchema) templatecatalog.SettingsSchema {
+	convert := func(options map[string]ConfigOption) map[string]templatecatalog.SettingRule {
+		if len(options) == 0 {
+			return nil
+		}
+		rules := make(map[string]templatecatalog.SettingRule, len(options))
+		for key, option := range options {
+			rules[key] = templatecatalog.SettingRule{
+				Type:    option.Type,
+				Default: option.Default,
+				Options: option.Options,
+				Min:     option.Min,
+				Max:     option.Max,
+				Pattern: option.Pattern,
+			}
+		}
+		return rules
+	}
+	return templatecatalog.SettingsSchema{
+		Settings:  convert(schema.Settings),
+		GameRules: convert(schema.GameRules),
+	}
+}
+
+// validateCallerSettings validates the FLEET_SETTING_* and FLEET_GAMERULE_*
+// keys of env against tmpl's schema. It returns a copy of env with schema
+// defaults added for missing keys, or the per-field errors.
+func validateCallerSettings(tmpl Template, env map[string]string) (map[string]string, []templatecatalog.FieldError) {
+	settings := make(map[string]string)
+	gameRules := make(map[string]string)
+	out := make(map[string]string, len(env))
+	for key, value := range env {
+		switch {
+		case strings.HasPrefix(key, settingEnvPrefix):
+			settings[strings.TrimPrefix(key, settingEnvPrefix)] = value
+		case strings.HasPrefix(key, gameRuleEnvPrefix):
+			gameRules[strings.TrimPrefix(key, gameRuleEnvPrefix)] = value
+		default:
+			out[key] = value
+		}
+	}
+	result := settingsSchema(tmpl.Config).Validate(settings, gameRules)
+	if !result.Valid {
+		return nil, result.Errors
+	}
+	for key, value := range result.Settings {
+		out[settingEnvPrefix+key] = value
+	}
+	for key, value := range result.GameRules {
+		out[gameRuleEnvPrefix+key] = value
+	}
+	return out, nil
+}
+
+// invalidSettings is the 400 a create or claim answers with when caller
+// settings fail validation.
+func invalidSettings(fields []templatecatalog.FieldError) *provisionError {
+	return &provisionError{status: 400, message: "invalid settings", fields: fields}
+}
+
+// writeProvisionError answers with perr's status and message, plus the
+// per-field errors when there are any.
+func writeProvisionError(c *pulpgin.Context, perr *provisionError) {
+	body := pulpgin.H{"error": perr.message}
+	if len(perr.fields) > 0 {
+		body["fields"] = perr.fields
+	}
+	c.JSON(perr.status, body)
+}
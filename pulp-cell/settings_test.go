@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidateCallerSettings(t *testing.T) {
+	maxPlayers := 100.0
+	tmpl := Template{Config: ConfigSchema{Settings: map[string]ConfigOption{
+		"difficulty":  {Type: "select", Default: "normal", Options: []string{"easy", "normal", "hard"}},
+		"max_players": {Type: "integer", Default: 20, Max: &maxPlayers},
+	}}}
+
+	env, fields := validateCallerSettings(tmpl, map[string]string{
+		"FLEET_SETTING_difficulty": "hard",
+		"FLEET_GAMERULE_anything":  "true",
+		"TYPE":                     "PAPER",
+	})
+	if len(fields) != 0 {
+		t.Fatalf("fields = %+v", fields)
+	}
+	want := map[string]string{
+		"FLEET_SETTING_difficulty":  "hard",
+		"FLEET_SETTING_max_players": "20",
+		"FLEET_GAMERULE_anything":   "true",
+		"TYPE":                      "PAPER",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("env = %v, want %v", env, want)
+	}
+
+	_, fields = validateCallerSettings(tmpl, map[string]string{"FLEET_SETTING_max_players": "500"})
+	if len(fields) != 1 || fields[0].Field != "settings.max_players" || fields[0].Code != "max" {
+		t.Fatalf("fields = %+v", fields)
+	}
+	perr := invalidSettings(fields)
+	if perr.status != 400 || len(perr.fields) != 1 {
+		t.Fatalf("provision error = %+v", perr)
+	}
+}
+
+func TestAdmissionReportsInvalidSettings(t *testing.T) {
+	p := newTestAdmissionProvisioner()
+	tmpl := p.templates["mc"]
+	tmpl.Config.Settings = map[string]ConfigOption{"pvp": {Type: "boolean"}}
+	p.templates["mc"] = tmpl
+	response, perr := p.admission(createServerRequest{Template: "mc", Env: map[string]string{"FLEET_SETTING_pvp": "maybe"}}, time.Unix(1000, 0))
+	if perr != nil {
+		t.Fatal(perr.message)
+	}
+	if dim := admissionDim(t, response, "settings"); response.Fits || dim.Fits || dim.Reason == "" {
+		t.Fatalf("response = %+v", response)
+	}
+}
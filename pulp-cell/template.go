@@ -20,6 +20,22 @@ type ConfigOption struct {
 	Label   string   `yaml:"label" json:"label"`
 	Options []string `yaml:"options,omitempty" json:"options,omitempty"`
 	Hint    string   `yaml:"hint,omitempty" json:"hint,omitempty"`
+	// Min and Max bound numeric options; Pattern is a regular expression
+	// the whole value must match. See templatecatalog.SettingRule.
+	Min     *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max     *float64 `yaml:"max,omitempty" json:"max,omitempty"`
+	Pattern string   `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	// Target says where the cell delivers the option's value; see
+	// config_targets.go.
+	Target *OptionTarget `yaml:"target,omitempty" json:"target,omitempty"`
+}
+
+// OptionTarget names where a setting lands: a container env var, a key in
+// the schema's properties file, a gamerule, or any combination.
+type OptionTarget struct {
+	Env      string `yaml:"env,omitempty" json:"env,omitempty"`
+	Property string `yaml:"property,omitempty" json:"property,omitempty"`
+	GameRule string `yaml:"gamerule,omitempty" json:"gamerule,omitempty"`
 }
 
 // EngineOption is a selectable server engine, declared per game in the template
@@ -36,10 +52,26 @@ type EngineOption struct {
 	Default   bool     `yaml:"default,omitempty" json:"default,omitempty"`
 }
 
+// PlatformExtension is a template option that lets the selected engine serve
+// more platforms when the caller turns it on, e.g. Geyser crossplay letting a
+// Java engine also serve Bedrock. It is on when env[Option] parses as true.
+type PlatformExtension struct {
+	Option string `yaml:"option" json:"option"`
+	Label  string `yaml:"label" json:"label"`
+	Hint   string `yaml:"hint,omitempty" json:"hint,omitempty"`
+	// Engines limits the extension to these engine values; empty means any.
+	Engines   []string `yaml:"engines,omitempty" json:"engines,omitempty"`
+	Platforms []string `yaml:"platforms" json:"platforms"`
+}
+
 type ConfigSchema struct {
-	Settings  map[string]ConfigOption `yaml:"settings,omitempty" json:"settings,omitempty"`
-	GameRules map[string]ConfigOption `yaml:"game_rules,omitempty" json:"game_rules,omitempty"`
-	Engines   []EngineOption          `yaml:"engines,omitempty" json:"engines,omitempty"`
+	Settings   map[string]ConfigOption `yaml:"settings,omitempty" json:"settings,omitempty"`
+	GameRules  map[string]ConfigOption `yaml:"game_rules,omitempty" json:"game_rules,omitempty"`
+	Engines    []EngineOption          `yaml:"engines,omitempty" json:"engines,omitempty"`
+	Extensions []PlatformExtension     `yaml:"extensions,omitempty" json:"extensions,omitempty"`
+	// PropertiesFile is the container path property targets edit; it
+	// defaults to /data/server.properties.
+	PropertiesFile string `yaml:"properties_file,omitempty" json:"properties_file,omitempty"`
 }
 
 type PortSpec struct {
@@ -48,6 +80,8 @@ type PortSpec struct {
 	Protocol  string `yaml:"protocol" json:"protocol"`
 	Name      string `yaml:"name,omitempty" json:"name,omitempty"`
 	Range     string `yaml:"range,omitempty" json:"range,omitempty"`
+	// Platforms the port serves; see filterPlatformPorts.
+	Platforms []string `yaml:"platforms,omitempty" json:"platforms,omitempty"`
 }
 
 type ContainerSpec struct {
@@ -58,6 +92,7 @@ type ContainerSpec struct {
 	Ports          []PortSpec        `yaml:"ports,omitempty" json:"ports,omitempty"`
 	Network        string            `yaml:"network,omitempty" json:"network,omitempty"`
 	IP             string            `yaml:"-" json:"-"`
+	IPv6           string            `yaml:"-" json:"-"`
 	CPULimit       float64           `yaml:"cpu_limit,omitempty" json:"cpu_limit"`
 	MemoryLimit    int64             `yaml:"memory_limit,omitempty" json:"memory_limit"`
 	DiskIOReadBps  int64             `yaml:"disk_io_read_bps,omitempty" json:"disk_io_read_bps,omitempty"`
@@ -79,6 +114,20 @@ type Template struct {
 	// specific value like "bedrock" = a dedicated variant image that engine
 	// routes to).
 	Engine string `yaml:"engine,omitempty" json:"engine,omitempty"`
+	// WarmPool is the number of idle standby containers the cell keeps
+	// running for this template so claims skip the cold boot.
+	WarmPool int `yaml:"warm_pool,omitempty" json:"warm_pool,omitempty"`
+	// Idle suspends servers of this template once they sit empty; see idle.go.
+	Idle *IdlePolicy `yaml:"idle,omitempty" json:"idle,omitempty"`
+	// Extends and Mixins name templates this one is layered on; see
+	// extends.go. Both are cleared once loadTemplates flattens the template.
+	Extends string   `yaml:"extends,omitempty" json:"extends,omitempty"`
+	Mixins  []string `yaml:"mixins,omitempty" json:"mixins,omitempty"`
+	// Files are config files rendered into the server's volume; see files.go.
+	Files []TemplateFile `yaml:"files,omitempty" json:"files,omitempty"`
+	// Abstract templates only exist to be extended or mixed in; they are
+	// not loaded as servable templates.
+	Abstract bool `yaml:"abstract,omitempty" json:"abstract,omitempty"`
 }
 
 // loadTemplates reads every *.yaml file under the cell's templates/
@@ -89,9 +138,12 @@ type Template struct {
 // pulp.FS.List which is scoped to the cell's storage root — the host
 // is expected to mount templates/ into that scoped root. Any .yaml or
 // .yml entry at the top level is considered a template.
-func loadTemplates(overrideFilenames []string) (map[string]Template, error) {
-	templates := make(map[string]Template)
-
+//
+// Templates are returned flattened by resolveTemplates. One whose extends or
+// mixins cannot be resolved is logged and skipped like an unparsable file.
+// The second map holds every parsed template as written, abstract ones
+// included, for PUT /admin/templates/:name to resolve against.
+func loadTemplates(overrideFilenames []string) (map[string]Template, map[string]Template, error) {
 	// Collect candidate filenames. If the operator supplied an explicit
 	// list via the `templates` config key we honor it verbatim (back-compat
 	// for pinning specific files). Otherwise we scan the directory.
@@ -106,7 +158,7 @@ func loadTemplates(overrideFilenames []string) (map[string]Template, error) {
 	} else {
 		entries, err := pulp.FS.List("templates")
 		if err != nil {
-			return nil, fmt.Errorf("list templates directory: %w", err)
+			return nil, nil, fmt.Errorf("list templates directory: %w", err)
 		}
 		for _, e := range entries {
 			if e.IsDir {
@@ -120,6 +172,7 @@ func loadTemplates(overrideFilenames []string) (map[string]Template, error) {
 		}
 	}
 
+	raw := make(map[string]Template)
 	for _, name := range names {
 		path := "templates/" + name
 		data, err := pulp.FS.Read(path)
@@ -139,9 +192,13 @@ func loadTemplates(overrideFilenames []string) (map[string]Template, error) {
 			log.Printf("Template %s has no name, skipping", name)
 			continue
 		}
-		templates[t.Name] = t
+		raw[t.Name] = t
 	}
-	return templates, nil
+	templates, errs := resolveTemplates(raw)
+	for name, err := range errs {
+		log.Printf("Failed to resolve template %s: %v", name, err)
+	}
+	return templates, raw, nil
 }
 
 func deepCopyContainer(src ContainerSpec) ContainerSpec {
@@ -184,6 +241,7 @@ func containerToCreateRequest(c ContainerSpec) docker.CreateRequest {
 		Ports:          ports,
 		Network:        c.Network,
 		IP:             c.IP,
+		IPv6:           c.IPv6,
 		MemoryLimit:    c.MemoryLimit,
 		CPULimit:       c.CPULimit,
 		DiskIOReadBps:  c.DiskIOReadBps,
@@ -193,4 +251,3 @@ func containerToCreateRequest(c ContainerSpec) docker.CreateRequest {
 		MemorySwap:     c.MemorySwap,
 	}
 }
-
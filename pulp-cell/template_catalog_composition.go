@@ -21,7 +21,9 @@ func callTemplateCatalog[T any](operation string, payload any) (templatecatalog.
 	)
 }
 
-func synchronizeTemplateCatalog(templates map[string]Template) error {
+// templateCatalogEntries projects loaded templates onto catalog entries,
+// sorted by name.
+func templateCatalogEntries(templates map[string]Template) ([]templatecatalog.Entry, error) {
 	names := make([]string, 0, len(templates))
 	for name := range templates {
 		names = append(names, name)
@@ -29,37 +31,59 @@ func synchronizeTemplateCatalog(templates map[string]Template) error {
 	sort.Strings(names)
 	entries := make([]templatecatalog.Entry, 0, len(names))
 	for _, name := range names {
-		template := templates[name]
-		configJSON, err := json.Marshal(template.Config)
-		if err != nil {
-			return fmt.Errorf("encode template %q config: %w", name, err)
-		}
-		runtimeJSON, err := json.Marshal(template)
+		entry, err := templateCatalogEntry(templates[name])
 		if err != nil {
-			return fmt.Errorf("encode template %q runtime: %w", name, err)
+			return nil, err
 		}
-		entries = append(entries, templatecatalog.Entry{
-			Name:        template.Name,
-			Game:        template.Game,
-			Label:       template.Label,
-			Engine:      template.Engine,
-			CPULimit:    template.Container.CPULimit,
-			MemoryLimit: template.Container.MemoryLimit,
-			ConfigJSON:  configJSON,
-			RuntimeJSON: runtimeJSON,
-		})
+		entries = append(entries, entry)
 	}
-	fingerprint, err := json.Marshal(entries)
+	return entries, nil
+}
+
+func templateCatalogEntry(template Template) (templatecatalog.Entry, error) {
+	configJSON, err := json.Marshal(template.Config)
 	if err != nil {
-		return fmt.Errorf("encode template catalog: %w", err)
+		return templatecatalog.Entry{}, fmt.Errorf("encode template %q config: %w", template.Name, err)
+	}
+	runtimeJSON, err := json.Marshal(template)
+	if err != nil {
+		return templatecatalog.Entry{}, fmt.Errorf("encode template %q runtime: %w", template.Name, err)
+	}
+	return templatecatalog.Entry{
+		Name:        template.Name,
+		Game:        template.Game,
+		Label:       template.Label,
+		Engine:      template.Engine,
+		CPULimit:    template.Container.CPULimit,
+		MemoryLimit: template.Container.MemoryLimit,
+		ConfigJSON:  configJSON,
+		RuntimeJSON: runtimeJSON,
+	}, nil
+}
+
+// synchronizeTemplateCatalog replaces the catalog with the loaded templates.
+// The request ID carries the catalog's current revision as well as the
+// content, so a retry replays while a reload after a PUT, DELETE or rollback
+// applies the template files again.
+func synchronizeTemplateCatalog(templates map[string]Template) error {
+	entries, err := templateCatalogEntries(templates)
+	if err != nil {
+		return err
+	}
+	history, err := callTemplateCatalog[templatecatalog.History](templatecatalog.FnHistory, map[string]any{})
+	if err != nil {
+		return err
+	}
+	if !history.OK {
+		return fmt.Errorf("template catalog history: %s", history.Error)
+	}
+	requestID, err := templateCatalogSyncRequestID(history.Value.Revision, entries)
+	if err != nil {
+		return err
 	}
-	digest := sha256.Sum256(fingerprint)
 	result, err := callTemplateCatalog[templatecatalog.Catalog](
 		templatecatalog.FnReplace,
-		templatecatalog.ReplaceRequest{
-			RequestID: "template-catalog/" + hex.EncodeToString(digest[:]),
-			Entries:   entries,
-		},
+		templatecatalog.ReplaceRequest{RequestID: requestID, Entries: entries},
 	)
 	if err != nil {
 		return err
@@ -69,3 +93,14 @@ func synchronizeTemplateCatalog(templates map[string]Template) error {
 	}
 	return nil
 }
+
+// templateCatalogSyncRequestID names a replace of the catalog at revision
+// with entries.
+func templateCatalogSyncRequestID(revision uint64, entries []templatecatalog.Entry) (string, error) {
+	fingerprint, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("encode template catalog: %w", err)
+	}
+	digest := sha256.Sum256(fingerprint)
+	return fmt.Sprintf("template-catalog/%d/%s", revision, hex.EncodeToString(digest[:])), nil
+}
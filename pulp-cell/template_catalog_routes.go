@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/templatecatalog"
+	"gopkg.in/yaml.v3"
+)
+
+// catalogRevision summarises a retained catalog revision for the admin API.
+// Entries are left out; their RuntimeJSON is the whole template.
+type catalogRevision struct {
+	Revision  uint64   `json:"revision"`
+	RequestID string   `json:"request_id"`
+	Templates []string `json:"templates"`
+}
+
+type rollbackRequest struct {
+	Revision uint64 `json:"revision"`
+}
+
+// templateUpsertRequest carries one template file's YAML.
+type templateUpsertRequest struct {
+	YAML string `json:"yaml"`
+}
+
+// templateUpsert is what a PUT changes: the raw templates with the pushed one
+// in place, and every servable template whose flattened form changed. That
+// is the pushed template unless it is abstract, plus whatever extends or
+// mixes it in.
+type templateUpsert struct {
+	raw     map[string]Template
+	changed map[string]Template
+}
+
+// parseTemplateUpsert parses a template pushed for name and re-resolves the
+// raw templates with it in place, so it can extend an abstract template and
+// its children pick up what it changes.
+func parseTemplateUpsert(name, document string, raw, served map[string]Template) (templateUpsert, error) {
+	var template Template
+	if err := yaml.Unmarshal([]byte(document), &template); err != nil {
+		return templateUpsert{}, fmt.Errorf("parse template: %w", err)
+	}
+	if template.Name == "" {
+		template.Name = name
+	}
+	if template.Name != name {
+		return templateUpsert{}, fmt.Errorf("template name %q does not match %q", template.Name, name)
+	}
+	if _, ok := served[name]; ok && template.Abstract {
+		return templateUpsert{}, fmt.Errorf("template %s is loaded; delete it before making it abstract", name)
+	}
+	next := make(map[string]Template, len(raw)+1)
+	for rawName, rawTemplate := range raw {
+		next[rawName] = rawTemplate
+	}
+	next[name] = template
+	resolved, errs := resolveTemplates(next)
+	if err := errs[name]; err != nil {
+		return templateUpsert{}, err
+	}
+	for servedName := range served {
+		if err := errs[servedName]; err != nil {
+			return templateUpsert{}, fmt.Errorf("template %s: %w", servedName, err)
+		}
+	}
+	if !template.Abstract && resolved[name].Game == "" {
+		return templateUpsert{}, fmt.Errorf("template game is required")
+	}
+	changed := make(map[string]Template)
+	for resolvedName, resolvedTemplate := range resolved {
+		prior, ok := served[resolvedName]
+		if resolvedName == name || !ok || !sameTemplate(prior, resolvedTemplate) {
+			changed[resolvedName] = resolvedTemplate
+		}
+	}
+	return templateUpsert{raw: next, changed: changed}, nil
+}
+
+// sameTemplate compares templates by the JSON the catalog stores, which a
+// template restored by rollback round-trips through.
+func sameTemplate(a, b Template) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// templateDependents lists the raw templates that extend or mix in name.
+func templateDependents(raw map[string]Template, name string) []string {
+	var dependents []string
+	for rawName, template := range raw {
+		if template.Extends == name || slices.Contains(template.Mixins, name) {
+			dependents = append(dependents, rawName)
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// templateRequestID is the catalog request ID for a per-template change: the
+// caller's Idempotency-Key when sent, else a fresh one. Upserting the same
+// YAML twice leaves the same entry either way.
+func templateRequestID(c *pulpgin.Context, action, name string) string {
+	key := c.GetHeader(fleetIdempotencyHeader)
+	if key == "" {
+		key = strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+	return "template-catalog/" + action + "/" + name + "/" + key
+}
+
+// templatesFromCatalog rebuilds the provisioner's templates from catalog
+// entries. synchronizeTemplateCatalog publishes each flattened template as
+// RuntimeJSON, so an entry without one cannot be served.
+func templatesFromCatalog(entries []templatecatalog.Entry) (map[string]Template, error) {
+	templates := make(map[string]Template, len(entries))
+	for _, entry := range entries {
+		if len(entry.RuntimeJSON) == 0 {
+			return nil, fmt.Errorf("template %q has no runtime definition", entry.Name)
+		}
+		var template Template
+		if err := json.Unmarshal(entry.RuntimeJSON, &template); err != nil {
+			return nil, fmt.Errorf("decode template %q: %w", entry.Name, err)
+		}
+		templates[entry.Name] = template
+	}
+	return templates, nil
+}
+
+// writeTemplateCatalogFailure maps a catalog service error onto the HTTP
+// status the admin routes return.
+func writeTemplateCatalogFailure(c *pulpgin.Context, serviceErr *templatecatalog.ServiceError) {
+	status, message := 500, "template catalog failed"
+	if serviceErr != nil {
+		switch serviceErr.Code {
+		case templatecatalog.CodeInvalidArgument:
+			status = 400
+		case templatecatalog.CodeNotFound:
+			status = 404
+		case templatecatalog.CodeConflict:
+			status = 409
+		}
+		if serviceErr.Message != "" {
+			message = serviceErr.Message
+		}
+	}
+	c.JSON(status, pulpgin.H{"error": message})
+}
+
+func writeTemplateCatalogUnavailable(c *pulpgin.Context, err error) {
+	log.Printf("[TemplateCatalog] composition unavailable: %v", err)
+	c.JSON(503, pulpgin.H{"error": "template catalog unavailable"})
+}
+
+// requestTemplateCatalogDiff asks the catalog for a diff and writes it,
+// listing empty sections as [] rather than null.
+func requestTemplateCatalogDiff(c *pulpgin.Context, request templatecatalog.DiffRequest) {
+	result, err := callTemplateCatalog[templatecatalog.Diff](templatecatalog.FnDiff, request)
+	if err != nil {
+		writeTemplateCatalogUnavailable(c, err)
+		return
+	}
+	if !result.OK {
+		writeTemplateCatalogFailure(c, result.Error)
+		return
+	}
+	diff := result.Value
+	if diff.Added == nil {
+		diff.Added = []string{}
+	}
+	if diff.Removed == nil {
+		diff.Removed = []string{}
+	}
+	if diff.Modified == nil {
+		diff.Modified = []templatecatalog.TemplateDiff{}
+	}
+	c.JSON(200, diff)
+}
+
+// writeTemplateCatalogDiff writes what replacing the catalog with templates
+// would change, without replacing it.
+func writeTemplateCatalogDiff(c *pulpgin.Context, templates map[string]Template) {
+	entries, err := templateCatalogEntries(templates)
+	if err != nil {
+		c.JSON(500, pulpgin.H{"error": err.Error()})
+		return
+	}
+	requestTemplateCatalogDiff(c, templatecatalog.DiffRequest{
+		Proposed: &templatecatalog.ReplaceRequest{Entries: entries},
+	})
+}
+
+func registerTemplateCatalogRoutes(group *pulpgin.RouterGroup, prov *provisioner, warm *warmPool) {
+	group.GET("/template-catalog/history", func(c *pulpgin.Context) {
+		result, err := callTemplateCatalog[templatecatalog.History](templatecatalog.FnHistory, map[string]any{})
+		if err != nil {
+			writeTemplateCatalogUnavailable(c, err)
+			return
+		}
+		if !result.OK {
+			writeTemplateCatalogFailure(c, result.Error)
+			return
+		}
+		revisions := make([]catalogRevision, 0, len(result.Value.Revisions))
+		for _, revision := range result.Value.Revisions {
+			names := make([]string, 0, len(revision.Entries))
+			for _, entry := range revision.Entries {
+				names = append(names, entry.Name)
+			}
+			revisions = append(revisions, catalogRevision{
+				Revision: revision.Revision, RequestID: revision.RequestID, Templates: names,
+			})
+		}
+		c.JSON(200, pulpgin.H{"revision": result.Value.Revision, "revisions": revisions})
+	})
+
+	// PUT and DELETE change one template in memory and in the catalog. The
+	// catalog moves first, so a failure there leaves the cell unchanged.
+	// Template files are not written: the next reload replaces both.
+	group.PUT("/templates/:name", func(c *pulpgin.Context) {
+		var req templateUpsertRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		name := c.Param("name")
+		upsert, err := parseTemplateUpsert(name, req.YAML, prov.rawTemplates, prov.templates)
+		if err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		updated := make([]string, 0, len(upsert.changed))
+		for changedName := range upsert.changed {
+			updated = append(updated, changedName)
+		}
+		sort.Strings(updated)
+		response := pulpgin.H{"template": name, "updated": updated}
+
+		// One changed template is an upsert. Re-resolved children go in
+		// with it as a single replace, so the catalog never holds a parent
+		// without its children.
+		if len(upsert.changed) > 0 {
+			requestID := templateRequestID(c, "upsert", name)
+			var operation string
+			var request any
+			if template, only := upsert.changed[name]; only && len(upsert.changed) == 1 {
+				entry, err := templateCatalogEntry(template)
+				if err != nil {
+					c.JSON(500, pulpgin.H{"error": err.Error()})
+					return
+				}
+				operation, request = templatecatalog.FnUpsert, templatecatalog.UpsertRequest{RequestID: requestID, Entry: entry}
+			} else {
+				next := make(map[string]Template, len(prov.templates)+len(upsert.changed))
+				for servedName, template := range prov.templates {
+					next[servedName] = template
+				}
+				for changedName, template := range upsert.changed {
+					next[changedName] = template
+				}
+				entries, err := templateCatalogEntries(next)
+				if err != nil {
+					c.JSON(500, pulpgin.H{"error": err.Error()})
+					return
+				}
+				operation, request = templatecatalog.FnReplace, templatecatalog.ReplaceRequest{RequestID: requestID, Entries: entries}
+			}
+			result, err := callTemplateCatalog[templatecatalog.Catalog](operation, request)
+			if err != nil {
+				writeTemplateCatalogUnavailable(c, err)
+				return
+			}
+			if !result.OK {
+				writeTemplateCatalogFailure(c, result.Error)
+				return
+			}
+			response["revision"] = result.Value.Revision
+		}
+
+		_, replaced := prov.rawTemplates[name]
+		prov.rawTemplates = upsert.raw
+		for _, changedName := range updated {
+			prov.templates[changedName] = upsert.changed[changedName]
+			warm.refillTemplate(changedName)
+		}
+		log.Printf("[TemplateCatalog] upserted template %s (updated %s)", name, strings.Join(updated, ", "))
+		status := 201
+		if replaced {
+			status = 200
+		}
+		c.JSON(status, response)
+	})
+
+	group.DELETE("/templates/:name", func(c *pulpgin.Context) {
+		name := c.Param("name")
+		if _, ok := prov.rawTemplates[name]; !ok {
+			c.JSON(404, pulpgin.H{"error": "template not found"})
+			return
+		}
+		if dependents := templateDependents(prov.rawTemplates, name); len(dependents) > 0 {
+			c.JSON(409, pulpgin.H{"error": fmt.Sprintf("template %s is extended or mixed in by %s", name, strings.Join(dependents, ", "))})
+			return
+		}
+		response := pulpgin.H{"template": name}
+		if _, served := prov.templates[name]; served {
+			result, err := callTemplateCatalog[templatecatalog.Catalog](
+				templatecatalog.FnDelete,
+				templatecatalog.DeleteRequest{RequestID: templateRequestID(c, "delete", name), Name: name},
+			)
+			if err != nil {
+				writeTemplateCatalogUnavailable(c, err)
+				return
+			}
+			// A catalog that already lacks the entry is what the delete wants.
+			if !result.OK && (result.Error == nil || result.Error.Code != templatecatalog.CodeNotFound) {
+				writeTemplateCatalogFailure(c, result.Error)
+				return
+			}
+			response["revision"] = result.Value.Revision
+			delete(prov.templates, name)
+			warm.refillTemplate(name)
+		}
+		delete(prov.rawTemplates, name)
+		log.Printf("[TemplateCatalog] deleted template %s", name)
+		c.JSON(200, response)
+	})
+
+	group.GET("/template-catalog/diff", func(c *pulpgin.Context) {
+		var request templatecatalog.DiffRequest
+		for param, revision := range map[string]*uint64{"from": &request.From, "to": &request.To} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(400, pulpgin.H{"error": "invalid " + param})
+				return
+			}
+			*revision = parsed
+		}
+		requestTemplateCatalogDiff(c, request)
+	})
+
+	// Rollback restores a retained revision in the catalog and in the
+	// templates the cell provisions from, without reading template files.
+	// The templates are decoded before the catalog moves, so a revision the
+	// cell cannot serve is refused rather than half applied.
+	group.POST("/template-catalog/rollback", func(c *pulpgin.Context) {
+		var req rollbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		if req.Revision == 0 {
+			c.JSON(400, pulpgin.H{"error": "revision required"})
+			return
+		}
+		history, err := callTemplateCatalog[templatecatalog.History](templatecatalog.FnHistory, map[string]any{})
+		if err != nil {
+			writeTemplateCatalogUnavailable(c, err)
+			return
+		}
+		if !history.OK {
+			writeTemplateCatalogFailure(c, history.Error)
+			return
+		}
+		var restored map[string]Template
+		for _, revision := range history.Value.Revisions {
+			if revision.Revision != req.Revision {
+				continue
+			}
+			if restored, err = templatesFromCatalog(revision.Entries); err != nil {
+				c.JSON(409, pulpgin.H{"error": err.Error()})
+				return
+			}
+		}
+		if restored == nil {
+			c.JSON(404, pulpgin.H{"error": "revision is not in the catalog history"})
+			return
+		}
+
+		// A retry without an Idempotency-Key after the rollback landed sees a
+		// newer current revision and rolls back again, which is harmless.
+		key := c.GetHeader(fleetIdempotencyHeader)
+		if key == "" {
+			key = fmt.Sprintf("%d-to-%d", history.Value.Revision, req.Revision)
+		}
+		rolled, err := callTemplateCatalog[templatecatalog.Catalog](
+			templatecatalog.FnRollback,
+			templatecatalog.RollbackRequest{RequestID: "template-catalog/rollback/" + key, Revision: req.Revision},
+		)
+		if err != nil {
+			writeTemplateCatalogUnavailable(c, err)
+			return
+		}
+		if !rolled.OK {
+			writeTemplateCatalogFailure(c, rolled.Error)
+			return
+		}
+		// Abstract templates have no catalog entry, so they stay; the
+		// restored templates are kept flattened until the next reload.
+		raw := make(map[string]Template, len(prov.rawTemplates)+len(restored))
+		for name, template := range prov.rawTemplates {
+			if template.Abstract {
+				raw[name] = template
+			}
+		}
+		for name, template := range restored {
+			raw[name] = template
+		}
+		prov.templates = restored
+		prov.rawTemplates = raw
+		warm.refill()
+		log.Printf("[TemplateCatalog] rolled back to revision %d as revision %d (%d templates)", req.Revision, rolled.Value.Revision, len(restored))
+		c.JSON(200, pulpgin.H{"revision": rolled.Value.Revision, "restored": req.Revision, "templates": len(restored)})
+	})
+}
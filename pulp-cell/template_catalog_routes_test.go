@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/bananalabs-oss/bananagine/templatecatalog"
+)
+
+func TestTemplatesFromCatalogRoundTripsRuntimeJSON(t *testing.T) {
+	templates := parseTemplates(t, `
+name: paper
+game: minecraft
+container:
+  image: itzg/minecraft-server
+  volumes:
+    "/var/sessions/worlds/{{SERVER_ID}}": "/data"
+  ports:
+    - {container: 25565, protocol: tcp, name: java}
+config:
+  settings:
+    pvp: {type: boolean, default: true, target: {property: pvp}}
+files:
+  - path: /data/ops.json
+    content: "[]"
+`)
+	runtimeJSON, err := json.Marshal(templates["paper"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := templatesFromCatalog([]templatecatalog.Entry{{Name: "paper", Game: "minecraft", RuntimeJSON: runtimeJSON}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, templates) {
+		t.Fatalf("restored = %+v, want %+v", restored["paper"], templates["paper"])
+	}
+	if _, err := templatesFromCatalog([]templatecatalog.Entry{{Name: "bare", Game: "minecraft"}}); err == nil {
+		t.Fatal("restored an entry without runtime_json")
+	}
+}
+
+func TestParseTemplateUpsertResolvesAgainstRawTemplates(t *testing.T) {
+	raw := parseTemplates(t, `
+name: mc-base
+abstract: true
+game: minecraft
+container:
+  image: itzg/minecraft-server
+  cpu_limit: 2
+`, `
+name: paper
+extends: mc-base
+label: Paper
+`)
+	served, errs := resolveTemplates(raw)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	upsert, err := parseTemplateUpsert("paper-event", `
+extends: mc-base
+label: Event
+container:
+  cpu_limit: 4
+`, raw, served)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := upsert.changed["paper-event"]
+	if len(upsert.changed) != 1 || template.Game != "minecraft" || template.Container.Image != "itzg/minecraft-server" || template.Container.CPULimit != 4 || template.Extends != "" {
+		t.Fatalf("changed = %+v", upsert.changed)
+	}
+	if _, ok := upsert.raw["paper-event"]; !ok || len(raw) != 2 {
+		t.Fatalf("raw = %v, loaded raw = %v", upsert.raw, raw)
+	}
+
+	upsert, err = parseTemplateUpsert("mc-base", `
+abstract: true
+game: minecraft
+container:
+  image: itzg/minecraft-server
+  cpu_limit: 3
+`, raw, served)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upsert.changed) != 1 || upsert.changed["paper"].Container.CPULimit != 3 || upsert.changed["paper"].Label != "Paper" {
+		t.Fatalf("parent upsert changed = %+v", upsert.changed)
+	}
+	if got := templateDependents(raw, "mc-base"); !reflect.DeepEqual(got, []string{"paper"}) {
+		t.Fatalf("dependents = %v", got)
+	}
+
+	for name, document := range map[string]string{
+		"invalid yaml":      "container: [",
+		"name mismatch":     "name: other\ngame: minecraft",
+		"missing game":      "label: Paper",
+		"unknown parent":    "extends: missing",
+		"inherit from self": "extends: paper-event",
+	} {
+		if _, err := parseTemplateUpsert("paper-event", document, raw, served); err == nil {
+			t.Errorf("%s: accepted %q", name, document)
+		}
+	}
+	if _, err := parseTemplateUpsert("paper", "game: minecraft\nabstract: true", raw, served); err == nil {
+		t.Error("made a loaded template abstract")
+	}
+	if _, err := parseTemplateUpsert("mc-base", "abstract: true\nextends: paper", raw, served); err == nil {
+		t.Error("accepted a parent that breaks its child")
+	}
+}
+
+func TestTemplateCatalogSyncRequestIDTracksRevision(t *testing.T) {
+	entries := []templatecatalog.Entry{{Name: "paper", Game: "minecraft"}}
+	first, err := templateCatalogSyncRequestID(3, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := templateCatalogSyncRequestID(3, entries); again != first {
+		t.Fatalf("retry request_id = %q, want %q", again, first)
+	}
+	if moved, _ := templateCatalogSyncRequestID(4, entries); moved == first {
+		t.Fatal("reload after the catalog moved reused its request_id")
+	}
+}
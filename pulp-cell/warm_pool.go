@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BananaLabs-OSS/Fiber/pulp/docker"
+	pulpgin "github.com/BananaLabs-OSS/Fiber/pulp/gin"
+	"github.com/bananalabs-oss/bananagine/orchestration"
+)
+
+const warmPoolStatePath = "warm-pool.json"
+
+// warmPool keeps Template.WarmPool idle containers per template running so a
+// claim hands out an already-booted server instead of waiting on a cold
+// create. Docker cannot rename a running container through the host
+// capability, so a claim rebinds the caller's ServerID to the standby in the
+// provisioner and persists that binding alongside the idle set.
+type warmPool struct {
+	prov  *provisioner
+	state warmPoolState
+}
+
+type warmPoolState struct {
+	Standbys map[string]warmStandby `json:"standbys"` // container ID -> idle standby
+	Claims   map[string]warmClaim   `json:"claims"`   // server ID -> claimed standby
+}
+
+type warmStandby struct {
+	Template  string `json:"template"`
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type warmClaim struct {
+	Template    string `json:"template"`
+	ContainerID string `json:"container_id"`
+	// Owner is charged at claim time; standbys are created ownerless, so the
+	// container carries no owner label and load re-charges from here.
+	Owner string `json:"owner,omitempty"`
+}
+
+func newWarmPool(prov *provisioner) *warmPool {
+	return &warmPool{
+		prov: prov,
+		state: warmPoolState{
+			Standbys: make(map[string]warmStandby),
+			Claims:   make(map[string]warmClaim),
+		},
+	}
+}
+
+// load restores the persisted idle set and claims, dropping any entry whose
+// container no longer exists, and rebinds claimed ServerIDs in the provisioner.
+func (w *warmPool) load() error {
+	var state warmPoolState
+	if found, err := loadCellState(warmPoolStatePath, &state); err != nil || !found {
+		return err
+	}
+	for id, standby := range state.Standbys {
+		if !containerGone(id) {
+			w.state.Standbys[id] = standby
+		}
+	}
+	for serverID, claim := range state.Claims {
+		if containerGone(claim.ContainerID) {
+			continue
+		}
+		w.state.Claims[serverID] = claim
+		w.prov.bindings[serverID] = claim.ContainerID
+		if claim.Owner == "" {
+			continue
+		}
+		if server, err := docker.Get(claim.ContainerID); err == nil && server != nil {
+			w.prov.quotas.charge(claim.ContainerID, claim.Owner, strings.TrimPrefix(server.Name, "/"), server.CPULimit, server.MemoryLimit)
+		} else {
+			log.Printf("[WarmPool] re-charge claim %s to %s: %v", serverID, claim.Owner, err)
+		}
+	}
+	return w.persist()
+}
+
+func (w *warmPool) persist() error {
+	return storeCellState(warmPoolStatePath, w.state)
+}
+
+// refill brings every template's idle set to its configured size: missing
+// standbys are created and surplus ones, including those of templates removed
+// by a reload, are destroyed. A capacity or allocation failure stops the
+// refill for that template; the next claim or reload tries again.
+func (w *warmPool) refill() {
+	for _, name := range w.templateNames() {
+		w.refillTemplate(name)
+	}
+}
+
+func (w *warmPool) refillTemplate(name string) {
+	size := w.prov.templates[name].WarmPool
+	idle := idleStandbys(w.state.Standbys, name)
+	for len(idle) > size {
+		// Surplus leaves newest first so the longest-warmed standbys remain.
+		containerID := idle[len(idle)-1]
+		idle = idle[:len(idle)-1]
+		w.retire(containerID)
+		if err := docker.Destroy(containerID); err != nil && !isDockerNotFound(err) {
+			log.Printf("[WarmPool] destroy surplus standby %s: %v", containerID, err)
+		}
+	}
+	for count := len(idle); count < size; count++ {
+		now := time.Now()
+		standbyName := fmt.Sprintf("%s-standby-%d", name, now.UnixNano())
+		server, perr := w.prov.create(createServerRequest{Template: name, ServerID: standbyName})
+		if perr != nil {
+			log.Printf("[WarmPool] refill %s stopped at %d/%d: %s", name, count, size, perr.message)
+			return
+		}
+		w.state.Standbys[server.ID] = warmStandby{Template: name, Name: standbyName, CreatedAt: now.Unix()}
+		if err := w.persist(); err != nil {
+			log.Printf("[WarmPool] persist state: %v", err)
+		}
+	}
+}
+
+// templateNames returns every loaded template plus any template that still
+// owns standbys after a reload removed it, sorted for deterministic refills.
+func (w *warmPool) templateNames() []string {
+	seen := make(map[string]struct{}, len(w.prov.templates))
+	for name := range w.prov.templates {
+		seen[name] = struct{}{}
+	}
+	for _, standby := range w.state.Standbys {
+		seen[standby.Template] = struct{}{}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// claim binds the oldest idle standby of req.Template to req.ServerID, applies
+// the caller's runtime settings, and refills the template's pool.
+func (w *warmPool) claim(req orchestration.ClaimServerRequest) (docker.Server, *provisionError) {
+	if !validFleetIdentity(req.ServerID) {
+		return docker.Server{}, &provisionError{status: 400, message: "valid server_id is required"}
+	}
+	if prior, claimed := w.state.Claims[req.ServerID]; claimed {
+		if prior.Template != req.Template {
+			return docker.Server{}, &provisionError{status: 409, message: "server_id was already claimed from a different template"}
+		}
+		server, err := docker.Get(prior.ContainerID)
+		if err != nil {
+			return docker.Server{}, provisionFailure(500, err)
+		}
+		return orchestrationResponseServer(*server, req.ServerID, w.prov.cfg.ExternalHost), nil
+	}
+	if _, ok := w.prov.templates[req.Template]; !ok {
+		return docker.Server{}, &provisionError{status: 404, message: "template not found"}
+	}
+	if req.Owner != "" && !validFleetIdentity(req.Owner) {
+		return docker.Server{}, &provisionError{status: 400, message: "invalid owner"}
+	}
+	if _, found, err := existingServerForRequestedID(req.ServerID, docker.Get); err != nil {
+		return docker.Server{}, provisionFailure(500, err)
+	} else if found {
+		return docker.Server{}, &provisionError{status: 409, message: "server_id is already in use"}
+	}
+	// Validate the runtime settings before touching a standby so a bad request
+	// never consumes or mutates one.
+	tmpl := w.prov.templates[req.Template]
+	if _, fields := validateCallerSettings(tmpl, req.Env); len(fields) > 0 {
+		return docker.Server{}, invalidSettings(fields)
+	}
+	if _, _, err := reconfigurePlan(tmpl, req.Env); err != nil {
+		return docker.Server{}, provisionFailure(400, err)
+	}
+
+	for _, containerID := range idleStandbys(w.state.Standbys, req.Template) {
+		server, err := docker.Get(containerID)
+		if err != nil {
+			if isDockerNotFound(err) {
+				w.retire(containerID)
+				continue
+			}
+			return docker.Server{}, provisionFailure(500, err)
+		}
+		// Standbys are uniform per template, so one that exceeds the owner's
+		// quota means every other one would too.
+		if err := w.prov.quotas.admit(req.Owner, server.CPULimit, server.MemoryLimit); err != nil {
+			return docker.Server{}, provisionFailure(403, err)
+		}
+		if len(req.Env) > 0 {
+			if err := executeFleetLifecycle("reconfigure", containerID, fleetLifecycleRequest{ServerID: req.ServerID, Env: req.Env}); err != nil {
+				// A partially reconfigured standby must not be handed to the
+				// next claimer, so it leaves the pool instead of going back.
+				w.retire(containerID)
+				if destroyErr := docker.Destroy(containerID); destroyErr != nil && !isDockerNotFound(destroyErr) {
+					log.Printf("[WarmPool] destroy failed standby %s: %v", containerID, destroyErr)
+				}
+				w.refillTemplate(req.Template)
+				return docker.Server{}, provisionFailure(422, err)
+			}
+		}
+		delete(w.state.Standbys, containerID)
+		w.state.Claims[req.ServerID] = warmClaim{Template: req.Template, ContainerID: containerID, Owner: req.Owner}
+		w.prov.bindings[req.ServerID] = containerID
+		w.prov.quotas.charge(containerID, req.Owner, strings.TrimPrefix(server.Name, "/"), server.CPULimit, server.MemoryLimit)
+		if err := w.persist(); err != nil {
+			log.Printf("[WarmPool] persist state: %v", err)
+		}
+		w.refillTemplate(req.Template)
+		return orchestrationResponseServer(*server, req.ServerID, w.prov.cfg.ExternalHost), nil
+	}
+	return docker.Server{}, &provisionError{status: 503, message: "no warm standby available for template"}
+}
+
+// retire drops a standby from the idle set and returns its leases.
+func (w *warmPool) retire(containerID string) {
+	delete(w.state.Standbys, containerID)
+	w.prov.release(containerID)
+	if err := w.persist(); err != nil {
+		log.Printf("[WarmPool] persist state: %v", err)
+	}
+}
+
+// forget removes a destroyed container from the idle set or its claim.
+func (w *warmPool) forget(containerID string) {
+	changed := false
+	if _, ok := w.state.Standbys[containerID]; ok {
+		delete(w.state.Standbys, containerID)
+		changed = true
+	}
+	for serverID, claim := range w.state.Claims {
+		if claim.ContainerID == containerID {
+			delete(w.state.Claims, serverID)
+			delete(w.prov.bindings, serverID)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := w.persist(); err != nil {
+		log.Printf("[WarmPool] persist state: %v", err)
+	}
+}
+
+func (w *warmPool) status() []orchestration.WarmPoolStatus {
+	result := make([]orchestration.WarmPoolStatus, 0)
+	for _, name := range w.templateNames() {
+		size := w.prov.templates[name].WarmPool
+		idle := len(idleStandbys(w.state.Standbys, name))
+		if size == 0 && idle == 0 {
+			continue
+		}
+		result = append(result, orchestration.WarmPoolStatus{Template: name, Size: size, Idle: idle})
+	}
+	return result
+}
+
+// idleStandbys returns template's standby container IDs, oldest first, so the
+// longest-booted server is handed out before a freshly started one.
+func idleStandbys(standbys map[string]warmStandby, template string) []string {
+	ids := make([]string, 0)
+	for id, standby := range standbys {
+		if standby.Template == template {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		left, right := standbys[ids[i]], standbys[ids[j]]
+		if left.CreatedAt == right.CreatedAt {
+			return ids[i] < ids[j]
+		}
+		return left.CreatedAt < right.CreatedAt
+	})
+	return ids
+}
+
+func registerWarmPoolRoutes(group *pulpgin.RouterGroup, pool *warmPool) {
+	group.GET("/warm-pool", func(c *pulpgin.Context) {
+		c.JSON(200, pool.status())
+	})
+	group.POST("/warm-pool/claim", func(c *pulpgin.Context) {
+		var req orchestration.ClaimServerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, pulpgin.H{"error": err.Error()})
+			return
+		}
+		req.Template = strings.TrimSpace(req.Template)
+		server, perr := pool.claim(req)
+		if perr != nil {
+			writeProvisionError(c, perr)
+			return
+		}
+		c.JSON(200, toOrchestrationServer(server))
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIdleStandbysAreTemplateScopedOldestFirst(t *testing.T) {
+	standbys := map[string]warmStandby{
+		"c-new":   {Template: "paper", CreatedAt: 30},
+		"c-old":   {Template: "paper", CreatedAt: 10},
+		"c-tie-b": {Template: "paper", CreatedAt: 20},
+		"c-tie-a": {Template: "paper", CreatedAt: 20},
+		"c-other": {Template: "fabric", CreatedAt: 1},
+	}
+	got := idleStandbys(standbys, "paper")
+	want := []string{"c-old", "c-tie-a", "c-tie-b", "c-new"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("idleStandbys = %v, want %v", got, want)
+	}
+	if got := idleStandbys(standbys, "bedrock"); len(got) != 0 {
+		t.Fatalf("unknown template standbys = %v, want none", got)
+	}
+}
+
+func TestWarmPoolStatusReportsConfiguredAndOrphanedTemplates(t *testing.T) {
+	pool := newWarmPool(&provisioner{
+		templates: map[string]Template{
+			"paper":  {Name: "paper", WarmPool: 2},
+			"fabric": {Name: "fabric"},
+		},
+		bindings: make(map[string]string),
+	})
+	pool.state.Standbys["c-1"] = warmStandby{Template: "paper", CreatedAt: 1}
+	pool.state.Standbys["c-2"] = warmStandby{Template: "removed", CreatedAt: 2}
+
+	got := pool.status()
+	if len(got) != 2 || got[0].Template != "paper" || got[0].Size != 2 || got[0].Idle != 1 ||
+		got[1].Template != "removed" || got[1].Size != 0 || got[1].Idle != 1 {
+		t.Fatalf("status = %#v", got)
+	}
+}
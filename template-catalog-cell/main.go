@@ -22,14 +22,30 @@ func init() {
 				value, err := state.Replace(request)
 				return encode(value, err)
 			},
+			templatecatalog.FnUpsert: func(input []byte) ([]byte, error) {
+				var request templatecatalog.UpsertRequest
+				if err := decode(input, &request); err != nil {
+					return nil, err
+				}
+				value, err := state.Upsert(request)
+				return encode(value, err)
+			},
+			templatecatalog.FnDelete: func(input []byte) ([]byte, error) {
+				var request templatecatalog.DeleteRequest
+				if err := decode(input, &request); err != nil {
+					return nil, err
+				}
+				value, err := state.Delete(request)
+				return encode(value, err)
+			},
 			templatecatalog.FnList: func(input []byte) ([]byte, error) {
+				var request templatecatalog.ListRequest
 				if len(input) > 0 {
-					var ignored map[string]any
-					if err := decode(input, &ignored); err != nil {
+					if err := decode(input, &request); err != nil {
 						return nil, err
 					}
 				}
-				return encode(state.List(), nil)
+				return encode(state.List(request), nil)
 			},
 			templatecatalog.FnGet: func(input []byte) ([]byte, error) {
 				var request templatecatalog.GetRequest
@@ -39,6 +55,39 @@ func init() {
 				value, err := state.Get(request.Name)
 				return encode(value, err)
 			},
+			templatecatalog.FnValidate: func(input []byte) ([]byte, error) {
+				var request templatecatalog.ValidateRequest
+				if err := decode(input, &request); err != nil {
+					return nil, err
+				}
+				value, err := state.Validate(request)
+				return encode(value, err)
+			},
+			templatecatalog.FnHistory: func(input []byte) ([]byte, error) {
+				if len(input) > 0 {
+					var ignored map[string]any
+					if err := decode(input, &ignored); err != nil {
+						return nil, err
+					}
+				}
+				return encode(state.History(), nil)
+			},
+			templatecatalog.FnRollback: func(input []byte) ([]byte, error) {
+				var request templatecatalog.RollbackRequest
+				if err := decode(input, &request); err != nil {
+					return nil, err
+				}
+				value, err := state.Rollback(request)
+				return encode(value, err)
+			},
+			templatecatalog.FnDiff: func(input []byte) ([]byte, error) {
+				var request templatecatalog.DiffRequest
+				if err := decode(input, &request); err != nil {
+					return nil, err
+				}
+				value, err := state.Diff(request)
+				return encode(value, err)
+			},
 			templatecatalog.FnSnapshotExport: func(input []byte) ([]byte, error) {
 				return encode(state.Export(), nil)
 			},
@@ -16,8 +16,18 @@ const (
 	FnGet            = Capability + ".get"
 	FnSnapshotExport = Capability + ".snapshot.export"
 	FnSnapshotImport = Capability + ".snapshot.import"
+	FnValidate       = Capability + ".validate"
+	FnHistory        = Capability + ".history"
+	FnRollback       = Capability + ".rollback"
+	FnDiff           = Capability + ".diff"
+	FnUpsert         = Capability + ".upsert"
+	FnDelete         = Capability + ".delete"
 
 	SnapshotVersion = 1
+
+	// HistoryDepth is how many catalog revisions the owner keeps, the
+	// current one included.
+	HistoryDepth = 10
 )
 
 type Entry struct {
@@ -36,19 +46,108 @@ type ReplaceRequest struct {
 	Entries   []Entry `json:"entries" msgpack:"entries"`
 }
 
+// UpsertRequest adds Entry or replaces the entry of the same name.
+type UpsertRequest struct {
+	RequestID string `json:"request_id" msgpack:"request_id"`
+	Entry     Entry  `json:"entry" msgpack:"entry"`
+}
+
+type DeleteRequest struct {
+	RequestID string `json:"request_id" msgpack:"request_id"`
+	Name      string `json:"name" msgpack:"name"`
+}
+
 type GetRequest struct {
 	Name string `json:"name" msgpack:"name"`
 }
 
+// ListRequest filters List. Game and Engine match exactly; Label matches any
+// entry whose label contains it, ignoring case. Empty fields match everything.
+type ListRequest struct {
+	Game   string `json:"game,omitempty" msgpack:"game,omitempty"`
+	Engine string `json:"engine,omitempty" msgpack:"engine,omitempty"`
+	Label  string `json:"label,omitempty" msgpack:"label,omitempty"`
+}
+
 type Catalog struct {
 	Revision uint64  `json:"revision" msgpack:"revision"`
 	Entries  []Entry `json:"entries" msgpack:"entries"`
+	// Facets is only set by List, and is nil when no entry matches.
+	Facets *Facets `json:"facets,omitempty" msgpack:"facets,omitempty"`
+}
+
+// Facets counts the distinct games and engines a List could narrow to. Each
+// facet applies every filter except its own, so picking a game still shows
+// the other games. Entries without an engine are not counted under engines.
+type Facets struct {
+	Games   []FacetCount `json:"games,omitempty" msgpack:"games,omitempty"`
+	Engines []FacetCount `json:"engines,omitempty" msgpack:"engines,omitempty"`
+}
+
+type FacetCount struct {
+	Value string `json:"value" msgpack:"value"`
+	Count int    `json:"count" msgpack:"count"`
 }
 
 type Snapshot struct {
 	Version  int     `json:"version" msgpack:"version"`
 	Revision uint64  `json:"revision" msgpack:"revision"`
 	Entries  []Entry `json:"entries" msgpack:"entries"`
+	// History carries the retained revisions so a restored owner can still
+	// roll back. Snapshots without it restore with only the current revision.
+	History []Revision `json:"history,omitempty" msgpack:"history,omitempty"`
+}
+
+// Revision is one retained catalog revision and the request that produced it.
+type Revision struct {
+	Revision  uint64  `json:"revision" msgpack:"revision"`
+	RequestID string  `json:"request_id" msgpack:"request_id"`
+	Entries   []Entry `json:"entries" msgpack:"entries"`
+}
+
+// History lists the retained revisions, newest first.
+type History struct {
+	Revision  uint64     `json:"revision" msgpack:"revision"`
+	Revisions []Revision `json:"revisions" msgpack:"revisions"`
+}
+
+// DiffRequest compares two retained revisions, or a retained revision
+// against a proposed catalog. A zero From or To is the current revision;
+// Proposed, when set, takes the place of To and its request_id is ignored.
+type DiffRequest struct {
+	From     uint64          `json:"from,omitempty" msgpack:"from,omitempty"`
+	To       uint64          `json:"to,omitempty" msgpack:"to,omitempty"`
+	Proposed *ReplaceRequest `json:"proposed,omitempty" msgpack:"proposed,omitempty"`
+}
+
+// Diff lists what changes going from From to To. To is zero when the
+// right-hand side was a proposed catalog.
+type Diff struct {
+	From     uint64         `json:"from" msgpack:"from"`
+	To       uint64         `json:"to" msgpack:"to"`
+	Added    []string       `json:"added" msgpack:"added"`
+	Removed  []string       `json:"removed" msgpack:"removed"`
+	Modified []TemplateDiff `json:"modified" msgpack:"modified"`
+}
+
+type TemplateDiff struct {
+	Name    string        `json:"name" msgpack:"name"`
+	Changes []FieldChange `json:"changes" msgpack:"changes"`
+}
+
+// FieldChange is one changed field. Fields inside ConfigJSON and RuntimeJSON
+// are dotted paths such as "config_json.settings.pvp.default"; arrays are
+// compared whole. Before is empty for an added field and After for a removed
+// one.
+type FieldChange struct {
+	Field  string          `json:"field" msgpack:"field"`
+	Before json.RawMessage `json:"before,omitempty" msgpack:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty" msgpack:"after,omitempty"`
+}
+
+type RollbackRequest struct {
+	RequestID string `json:"request_id" msgpack:"request_id"`
+	Revision  uint64 `json:"revision" msgpack:"revision"`
 }
 
 type ImportRequest struct {
@@ -0,0 +1,171 @@
+package templatecatalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Diff compares the catalog at two revisions, or at one revision and a
+// proposed replacement, without changing either.
+func (s *State) Diff(request DiffRequest) (Diff, error) {
+	if s == nil {
+		return Diff{}, internal("template catalog is unavailable")
+	}
+	from, before, err := s.revisionEntries(request.From)
+	if err != nil {
+		return Diff{}, err
+	}
+	var to uint64
+	var after map[string]Entry
+	if request.Proposed != nil {
+		after, _, err = validateEntries(request.Proposed.Entries)
+	} else {
+		to, after, err = s.revisionEntries(request.To)
+	}
+	if err != nil {
+		return Diff{}, err
+	}
+	diff := diffEntries(before, after)
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// revisionEntries resolves a revision number, zero meaning the current one,
+// to its entries.
+func (s *State) revisionEntries(revision uint64) (uint64, map[string]Entry, error) {
+	if revision == 0 || revision == s.revision {
+		return s.revision, s.entries, nil
+	}
+	for _, retained := range s.history {
+		if retained.Revision != revision {
+			continue
+		}
+		entries := make(map[string]Entry, len(retained.Entries))
+		for _, entry := range retained.Entries {
+			entries[entry.Name] = entry
+		}
+		return revision, entries, nil
+	}
+	return 0, nil, notFound("revision is not in the catalog history")
+}
+
+func diffEntries(before, after map[string]Entry) Diff {
+	var diff Diff
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	names := make([]string, 0, len(before))
+	for name := range before {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		next, ok := after[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, name)
+			continue
+		}
+		if changes := entryChanges(before[name], next); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, TemplateDiff{Name: name, Changes: changes})
+		}
+	}
+	sort.Strings(diff.Added)
+	return diff
+}
+
+func entryChanges(before, after Entry) []FieldChange {
+	var changes []FieldChange
+	for _, field := range []struct {
+		name          string
+		before, after any
+	}{
+		{"game", before.Game, after.Game},
+		{"label", before.Label, after.Label},
+		{"engine", before.Engine, after.Engine},
+		{"cpu_limit", before.CPULimit, after.CPULimit},
+		{"memory_limit", before.MemoryLimit, after.MemoryLimit},
+	} {
+		if field.before != field.after {
+			changes = append(changes, FieldChange{
+				Field:  field.name,
+				Before: encodeValue(field.before),
+				After:  encodeValue(field.after),
+			})
+		}
+	}
+	changes = diffDocuments("config_json", before.ConfigJSON, after.ConfigJSON, changes)
+	return diffDocuments("runtime_json", before.RuntimeJSON, after.RuntimeJSON, changes)
+}
+
+// diffDocuments appends the field changes between two JSON documents,
+// descending into objects both sides have. A field only one side has is a
+// single change carrying its whole value.
+func diffDocuments(path string, before, after json.RawMessage, changes []FieldChange) []FieldChange {
+	if bytes.Equal(before, after) {
+		return changes
+	}
+	beforeValue, hasBefore := decodeDocument(before)
+	afterValue, hasAfter := decodeDocument(after)
+	return diffValues(path, beforeValue, afterValue, hasBefore, hasAfter, changes)
+}
+
+func diffValues(path string, before, after any, hasBefore, hasAfter bool, changes []FieldChange) []FieldChange {
+	beforeObject, beforeIsObject := before.(map[string]any)
+	afterObject, afterIsObject := after.(map[string]any)
+	if hasBefore && hasAfter && beforeIsObject && afterIsObject {
+		keys := make([]string, 0, len(beforeObject)+len(afterObject))
+		for key := range beforeObject {
+			keys = append(keys, key)
+		}
+		for key := range afterObject {
+			if _, shared := beforeObject[key]; !shared {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			nextBefore, inBefore := beforeObject[key]
+			nextAfter, inAfter := afterObject[key]
+			changes = diffValues(path+"."+key, nextBefore, nextAfter, inBefore, inAfter, changes)
+		}
+		return changes
+	}
+	if hasBefore && hasAfter && reflect.DeepEqual(before, after) {
+		return changes
+	}
+	change := FieldChange{Field: path}
+	if hasBefore {
+		change.Before = encodeValue(before)
+	}
+	if hasAfter {
+		change.After = encodeValue(after)
+	}
+	return append(changes, change)
+}
+
+// decodeDocument decodes validated JSON, keeping numbers exact so unchanged
+// values compare equal and changed ones are reported as written.
+func decodeDocument(document json.RawMessage) (any, bool) {
+	if len(document) == 0 {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func encodeValue(value any) json.RawMessage {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
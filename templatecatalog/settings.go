@@ -0,0 +1,199 @@
+package templatecatalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SettingRule is the validation view of one template config option. It
+// decodes from the option objects in an entry's ConfigJSON.
+type SettingRule struct {
+	Type    string   `json:"type" msgpack:"type"`
+	Default any      `json:"default" msgpack:"default"`
+	Options []string `json:"options,omitempty" msgpack:"options,omitempty"`
+	Min     *float64 `json:"min,omitempty" msgpack:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty" msgpack:"max,omitempty"`
+	Pattern string   `json:"pattern,omitempty" msgpack:"pattern,omitempty"`
+}
+
+// SettingsSchema is the part of an entry's ConfigJSON that caller settings
+// are validated against.
+type SettingsSchema struct {
+	Settings  map[string]SettingRule `json:"settings,omitempty" msgpack:"settings,omitempty"`
+	GameRules map[string]SettingRule `json:"game_rules,omitempty" msgpack:"game_rules,omitempty"`
+}
+
+type ValidateRequest struct {
+	Name      string            `json:"name" msgpack:"name"`
+	Settings  map[string]string `json:"settings,omitempty" msgpack:"settings,omitempty"`
+	GameRules map[string]string `json:"game_rules,omitempty" msgpack:"game_rules,omitempty"`
+}
+
+// Field error codes.
+const (
+	FieldUnknown = "unknown"
+	FieldType    = "type"
+	FieldEnum    = "enum"
+	FieldMin     = "min"
+	FieldMax     = "max"
+	FieldPattern = "pattern"
+	FieldSchema  = "schema"
+)
+
+// FieldError reports one invalid value. Field is "settings.<key>" or
+// "game_rules.<key>".
+type FieldError struct {
+	Field   string `json:"field" msgpack:"field"`
+	Code    string `json:"code" msgpack:"code"`
+	Message string `json:"message" msgpack:"message"`
+}
+
+// Validation is the outcome of validating caller settings. Settings and
+// GameRules hold the caller's values with schema defaults filled in.
+type Validation struct {
+	Valid     bool              `json:"valid" msgpack:"valid"`
+	Settings  map[string]string `json:"settings,omitempty" msgpack:"settings,omitempty"`
+	GameRules map[string]string `json:"game_rules,omitempty" msgpack:"game_rules,omitempty"`
+	Errors    []FieldError      `json:"errors,omitempty" msgpack:"errors,omitempty"`
+}
+
+// ParseSettingsSchema reads the settings schema out of an entry's ConfigJSON.
+func ParseSettingsSchema(configJSON json.RawMessage) (SettingsSchema, error) {
+	var schema SettingsSchema
+	if len(configJSON) == 0 {
+		return schema, nil
+	}
+	if err := json.Unmarshal(configJSON, &schema); err != nil {
+		return SettingsSchema{}, fmt.Errorf("decode settings schema: %w", err)
+	}
+	return schema, nil
+}
+
+// Validate checks settings and gameRules against the schema. A section the
+// schema does not declare is passed through unchecked, so templates without a
+// schema accept anything; in a declared section an undeclared key is an error
+// and declared keys missing from the input get their default. Errors are
+// sorted by field.
+func (s SettingsSchema) Validate(settings, gameRules map[string]string) Validation {
+	var errs []FieldError
+	result := Validation{
+		Settings:  validateSection("settings", s.Settings, settings, &errs),
+		GameRules: validateSection("game_rules", s.GameRules, gameRules, &errs),
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	result.Errors = errs
+	result.Valid = len(errs) == 0
+	return result
+}
+
+func validateSection(section string, rules map[string]SettingRule, values map[string]string, errs *[]FieldError) map[string]string {
+	if len(rules) == 0 {
+		if len(values) == 0 {
+			return nil
+		}
+		out := make(map[string]string, len(values))
+		for key, value := range values {
+			out[key] = value
+		}
+		return out
+	}
+	out := make(map[string]string, len(rules))
+	for key, value := range values {
+		field := section + "." + key
+		rule, ok := rules[key]
+		if !ok {
+			*errs = append(*errs, FieldError{Field: field, Code: FieldUnknown, Message: fmt.Sprintf("%s is not a declared option", field)})
+			continue
+		}
+		if err := rule.check(field, value); err != nil {
+			*errs = append(*errs, *err)
+			continue
+		}
+		out[key] = value
+	}
+	for key, rule := range rules {
+		if _, given := values[key]; given || rule.Default == nil {
+			continue
+		}
+		out[key] = formatDefault(rule.Default)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// check validates one value. Numeric types ("number", "integer", "int",
+// "float", "slider") must parse, integers must be whole, and min/max bound
+// the number. Boolean types ("boolean", "bool", "toggle") must parse as a
+// bool. Options, when declared, list every allowed value for any type;
+// pattern must match the whole value.
+func (r SettingRule) check(field, value string) *FieldError {
+	fail := func(code, format string, args ...any) *FieldError {
+		return &FieldError{Field: field, Code: code, Message: field + " " + fmt.Sprintf(format, args...)}
+	}
+	switch strings.ToLower(r.Type) {
+	case "boolean", "bool", "toggle":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fail(FieldType, "must be true or false")
+		}
+	case "number", "integer", "int", "float", "slider":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return fail(FieldType, "must be a number")
+		}
+		if t := strings.ToLower(r.Type); (t == "integer" || t == "int") && n != math.Trunc(n) {
+			return fail(FieldType, "must be a whole number")
+		}
+		if r.Min != nil && n < *r.Min {
+			return fail(FieldMin, "must be at least %s", formatNumber(*r.Min))
+		}
+		if r.Max != nil && n > *r.Max {
+			return fail(FieldMax, "must be at most %s", formatNumber(*r.Max))
+		}
+	}
+	if len(r.Options) > 0 && !containsString(r.Options, value) {
+		return fail(FieldEnum, "must be one of %s", strings.Join(r.Options, ", "))
+	}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + r.Pattern + `)$`)
+		if err != nil {
+			return fail(FieldSchema, "has an invalid pattern in the template")
+		}
+		if !pattern.MatchString(value) {
+			return fail(FieldPattern, "must match %s", r.Pattern)
+		}
+	}
+	return nil
+}
+
+func formatDefault(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	case float32:
+		return formatNumber(float64(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
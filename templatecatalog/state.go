@@ -3,6 +3,7 @@ package templatecatalog
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strings"
 )
@@ -11,6 +12,9 @@ type State struct {
 	revision uint64
 	entries  map[string]Entry
 	requests map[string][]byte
+	// history holds the last HistoryDepth revisions, oldest first. The
+	// newest is always the current catalog.
+	history []Revision
 }
 
 func NewState() *State {
@@ -20,6 +24,9 @@ func NewState() *State {
 	}
 }
 
+// Replace swaps in a whole catalog as a new revision. A request_id replays
+// only with the same entries, and a replay returns the current catalog
+// without applying them again.
 func (s *State) Replace(request ReplaceRequest) (Catalog, error) {
 	if s == nil {
 		return Catalog{}, internal("template catalog is unavailable")
@@ -38,17 +45,108 @@ func (s *State) Replace(request ReplaceRequest) (Catalog, error) {
 		}
 		return s.catalog(), nil
 	}
-	s.entries = entries
-	s.revision++
-	s.requests[request.RequestID] = append([]byte(nil), fingerprint...)
+	s.apply(request.RequestID, entries, fingerprint)
+	return s.catalog(), nil
+}
+
+// Upsert adds or replaces one entry as a new revision. Like Replace, a
+// request_id replays only with the same entry.
+func (s *State) Upsert(request UpsertRequest) (Catalog, error) {
+	if s == nil {
+		return Catalog{}, internal("template catalog is unavailable")
+	}
+	request.RequestID = strings.TrimSpace(request.RequestID)
+	if request.RequestID == "" {
+		return Catalog{}, invalid("request_id is required")
+	}
+	entries, fingerprint, err := validateEntries([]Entry{request.Entry})
+	if err != nil {
+		return Catalog{}, err
+	}
+	fingerprint = append([]byte("upsert:"), fingerprint...)
+	if prior, exists := s.requests[request.RequestID]; exists {
+		if !bytes.Equal(prior, fingerprint) {
+			return Catalog{}, conflict("request_id was already used for a different entry")
+		}
+		return s.catalog(), nil
+	}
+	next := make(map[string]Entry, len(s.entries)+1)
+	for name, entry := range s.entries {
+		next[name] = entry
+	}
+	for name, entry := range entries {
+		next[name] = entry
+	}
+	s.apply(request.RequestID, next, fingerprint)
+	return s.catalog(), nil
+}
+
+// Delete removes one entry as a new revision.
+func (s *State) Delete(request DeleteRequest) (Catalog, error) {
+	if s == nil {
+		return Catalog{}, internal("template catalog is unavailable")
+	}
+	request.RequestID = strings.TrimSpace(request.RequestID)
+	if request.RequestID == "" {
+		return Catalog{}, invalid("request_id is required")
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		return Catalog{}, invalid("template name is required")
+	}
+	fingerprint := []byte("delete:" + request.Name)
+	if prior, exists := s.requests[request.RequestID]; exists {
+		if !bytes.Equal(prior, fingerprint) {
+			return Catalog{}, conflict("request_id was already used for a different entry")
+		}
+		return s.catalog(), nil
+	}
+	if _, ok := s.entries[request.Name]; !ok {
+		return Catalog{}, notFound("template not found")
+	}
+	next := make(map[string]Entry, len(s.entries))
+	for name, entry := range s.entries {
+		if name != request.Name {
+			next[name] = entry
+		}
+	}
+	s.apply(request.RequestID, next, fingerprint)
 	return s.catalog(), nil
 }
 
-func (s *State) List() Catalog {
+// List returns the entries matching request and the facets to narrow them
+// further; see Facets.
+func (s *State) List(request ListRequest) Catalog {
 	if s == nil {
 		return Catalog{}
 	}
-	return s.catalog()
+	request.Game = strings.TrimSpace(request.Game)
+	request.Engine = strings.TrimSpace(request.Engine)
+	request.Label = strings.ToLower(strings.TrimSpace(request.Label))
+
+	catalog := s.catalog()
+	var entries []Entry
+	games := make(map[string]int)
+	engines := make(map[string]int)
+	for _, entry := range catalog.Entries {
+		gameMatch := request.Game == "" || entry.Game == request.Game
+		engineMatch := request.Engine == "" || entry.Engine == request.Engine
+		labelMatch := request.Label == "" || strings.Contains(strings.ToLower(entry.Label), request.Label)
+		if engineMatch && labelMatch {
+			games[entry.Game]++
+		}
+		if gameMatch && labelMatch && entry.Engine != "" {
+			engines[entry.Engine]++
+		}
+		if gameMatch && engineMatch && labelMatch {
+			entries = append(entries, entry)
+		}
+	}
+	catalog.Entries = entries
+	if len(games) > 0 || len(engines) > 0 {
+		catalog.Facets = &Facets{Games: facetCounts(games), Engines: facetCounts(engines)}
+	}
+	return catalog
 }
 
 func (s *State) Get(name string) (Entry, error) {
@@ -66,12 +164,79 @@ func (s *State) Get(name string) (Entry, error) {
 	return cloneEntry(entry), nil
 }
 
+// Validate checks caller settings against the named entry's settings schema.
+func (s *State) Validate(request ValidateRequest) (Validation, error) {
+	entry, err := s.Get(request.Name)
+	if err != nil {
+		return Validation{}, err
+	}
+	schema, err := ParseSettingsSchema(entry.ConfigJSON)
+	if err != nil {
+		return Validation{}, invalid("template config_json has no valid settings schema")
+	}
+	return schema.Validate(request.Settings, request.GameRules), nil
+}
+
+// History returns the retained revisions, newest first.
+func (s *State) History() History {
+	if s == nil {
+		return History{}
+	}
+	history := History{Revision: s.revision}
+	for i := len(s.history) - 1; i >= 0; i-- {
+		history.Revisions = append(history.Revisions, cloneRevision(s.history[i]))
+	}
+	return history
+}
+
+// Rollback makes a retained revision's entries the catalog again, as a new
+// revision. The request IDs of the revisions it undoes are forgotten, so
+// replacing with the same entries again applies them instead of replaying.
+func (s *State) Rollback(request RollbackRequest) (Catalog, error) {
+	if s == nil {
+		return Catalog{}, internal("template catalog is unavailable")
+	}
+	request.RequestID = strings.TrimSpace(request.RequestID)
+	if request.RequestID == "" {
+		return Catalog{}, invalid("request_id is required")
+	}
+	if request.Revision == 0 {
+		return Catalog{}, invalid("revision is required")
+	}
+	fingerprint := []byte(fmt.Sprintf("rollback:%d", request.Revision))
+	if prior, exists := s.requests[request.RequestID]; exists {
+		if !bytes.Equal(prior, fingerprint) {
+			return Catalog{}, conflict("request_id was already used for a different catalog")
+		}
+		return s.catalog(), nil
+	}
+	target := -1
+	for i, revision := range s.history {
+		if revision.Revision == request.Revision {
+			target = i
+		}
+	}
+	if target < 0 {
+		return Catalog{}, notFound("revision is not in the catalog history")
+	}
+	entries := make(map[string]Entry, len(s.history[target].Entries))
+	for _, entry := range s.history[target].Entries {
+		entries[entry.Name] = cloneEntry(entry)
+	}
+	for _, undone := range s.history[target+1:] {
+		delete(s.requests, undone.RequestID)
+	}
+	s.apply(request.RequestID, entries, fingerprint)
+	return s.catalog(), nil
+}
+
 func (s *State) Export() Snapshot {
-	catalog := s.List()
+	catalog := s.List(ListRequest{})
 	return Snapshot{
 		Version:  SnapshotVersion,
 		Revision: catalog.Revision,
 		Entries:  catalog.Entries,
+		History:  s.History().Revisions,
 	}
 }
 
@@ -93,12 +258,73 @@ func (s *State) Import(request ImportRequest) (Catalog, error) {
 		}
 		return s.catalog(), nil
 	}
+	history, currentRequestID, err := importHistory(request.Snapshot)
+	if err != nil {
+		return Catalog{}, err
+	}
+	if currentRequestID == "" {
+		currentRequestID = request.RequestID
+	}
 	s.entries = entries
 	s.revision = request.Snapshot.Revision
 	s.requests[request.RequestID] = append([]byte(nil), fingerprint...)
+	s.history = history
+	s.record(currentRequestID)
 	return s.catalog(), nil
 }
 
+// apply makes entries the catalog as a new revision produced by requestID.
+func (s *State) apply(requestID string, entries map[string]Entry, fingerprint []byte) {
+	s.entries = entries
+	s.revision++
+	s.requests[requestID] = append([]byte(nil), fingerprint...)
+	s.record(requestID)
+}
+
+// record appends the current catalog to the history, dropping the oldest
+// revisions past HistoryDepth.
+func (s *State) record(requestID string) {
+	s.history = append(s.history, Revision{
+		Revision:  s.revision,
+		RequestID: requestID,
+		Entries:   s.catalog().Entries,
+	})
+	if excess := len(s.history) - HistoryDepth; excess > 0 {
+		s.history = append([]Revision(nil), s.history[excess:]...)
+	}
+}
+
+// importHistory validates a snapshot's retained revisions older than its
+// current one and orders them oldest first. Import records the current
+// revision itself, under the request ID the snapshot recorded for it.
+func importHistory(snapshot Snapshot) ([]Revision, string, error) {
+	var history []Revision
+	currentRequestID := ""
+	for _, revision := range snapshot.History {
+		if revision.Revision == snapshot.Revision {
+			currentRequestID = revision.RequestID
+		}
+		if revision.Revision >= snapshot.Revision {
+			continue
+		}
+		entries, _, err := validateEntries(revision.Entries)
+		if err != nil {
+			return nil, "", err
+		}
+		restored := (&State{revision: revision.Revision, entries: entries}).catalog()
+		history = append(history, Revision{
+			Revision:  revision.Revision,
+			RequestID: revision.RequestID,
+			Entries:   restored.Entries,
+		})
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Revision < history[j].Revision })
+	if excess := len(history) - (HistoryDepth - 1); excess > 0 {
+		history = history[excess:]
+	}
+	return history, currentRequestID, nil
+}
+
 func (s *State) catalog() Catalog {
 	names := make([]string, 0, len(s.entries))
 	for name := range s.entries {
@@ -165,6 +391,26 @@ func cloneEntry(entry Entry) Entry {
 	return entry
 }
 
+// facetCounts orders counts by value. Nil when there are none, for the same
+// Lua reason as catalog.
+func facetCounts(counts map[string]int) []FacetCount {
+	var facets []FacetCount
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Value < facets[j].Value })
+	return facets
+}
+
+func cloneRevision(revision Revision) Revision {
+	entries := revision.Entries
+	revision.Entries = nil
+	for _, entry := range entries {
+		revision.Entries = append(revision.Entries, cloneEntry(entry))
+	}
+	return revision
+}
+
 func invalid(message string) error {
 	return &ServiceError{Code: CodeInvalidArgument, Message: message}
 }
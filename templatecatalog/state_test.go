@@ -46,7 +46,7 @@ func TestStateSnapshotRestoresRestartedOwnerAndRemainsIsolated(t *testing.T) {
 		t.Fatal(err)
 	}
 	restarted := NewState()
-	if got := restarted.List(); got.Revision != 0 || len(got.Entries) != 0 {
+	if got := restarted.List(ListRequest{}); got.Revision != 0 || len(got.Entries) != 0 {
 		t.Fatalf("fresh owner leaked state: %#v", got)
 	}
 	restored, err := restarted.Import(ImportRequest{RequestID: "restore", Snapshot: original.Export()})
@@ -57,7 +57,317 @@ func TestStateSnapshotRestoresRestartedOwnerAndRemainsIsolated(t *testing.T) {
 		t.Fatalf("restored catalog = %#v", restored)
 	}
 	other := NewState()
-	if len(other.List().Entries) != 0 {
+	if len(other.List(ListRequest{}).Entries) != 0 {
 		t.Fatal("independent owner shared restored state")
 	}
 }
+
+func TestStateValidateReportsFieldErrorsAndDefaults(t *testing.T) {
+	state := NewState()
+	_, err := state.Replace(ReplaceRequest{
+		RequestID: "replace",
+		Entries: []Entry{{Name: "paper", Game: "minecraft", ConfigJSON: json.RawMessage(`{
+			"settings": {
+				"difficulty": {"type": "select", "default": "normal", "options": ["easy", "normal", "hard"]},
+				"max_players": {"type": "integer", "default": 20, "min": 1, "max": 100},
+				"motd": {"type": "text", "default": "", "pattern": "[A-Za-z ]{0,32}"},
+				"pvp": {"type": "boolean", "default": true}
+			},
+			"game_rules": {"keepInventory": {"type": "boolean", "default": false}}
+		}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := state.Validate(ValidateRequest{Name: "paper", Settings: map[string]string{"difficulty": "hard"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSettings := map[string]string{"difficulty": "hard", "max_players": "20", "motd": "", "pvp": "true"}
+	if !valid.Valid || !reflect.DeepEqual(valid.Settings, wantSettings) || valid.GameRules["keepInventory"] != "false" {
+		t.Fatalf("validation = %#v", valid)
+	}
+
+	invalid, err := state.Validate(ValidateRequest{
+		Name:      "paper",
+		Settings:  map[string]string{"difficulty": "brutal", "max_players": "250", "motd": "hi!", "pvp": "maybe"},
+		GameRules: map[string]string{"doDaylightCycle": "false"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fieldErr := range invalid.Errors {
+		got = append(got, fieldErr.Field+":"+fieldErr.Code)
+	}
+	want := []string{
+		"game_rules.doDaylightCycle:unknown",
+		"settings.difficulty:enum",
+		"settings.max_players:max",
+		"settings.motd:pattern",
+		"settings.pvp:type",
+	}
+	if invalid.Valid || !reflect.DeepEqual(got, want) {
+		t.Fatalf("errors = %v, want %v", got, want)
+	}
+
+	if _, err := state.Validate(ValidateRequest{Name: "missing"}); err == nil {
+		t.Fatal("validated against a missing template")
+	}
+}
+
+func TestStateRollbackRestoresRetainedRevision(t *testing.T) {
+	state := NewState()
+	replace := func(id, mode string) {
+		t.Helper()
+		_, err := state.Replace(ReplaceRequest{
+			RequestID: id,
+			Entries:   []Entry{{Name: "paper", Game: "minecraft", ConfigJSON: json.RawMessage(`{"mode":"` + mode + `"}`)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	replace("good", "good")
+	replace("bad", "bad")
+
+	rolled, err := state.Rollback(RollbackRequest{RequestID: "undo", Revision: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolled.Revision != 3 || string(rolled.Entries[0].ConfigJSON) != `{"mode":"good"}` {
+		t.Fatalf("rolled back catalog = %#v", rolled)
+	}
+	if replayed, err := state.Rollback(RollbackRequest{RequestID: "undo", Revision: 1}); err != nil || replayed.Revision != 3 {
+		t.Fatalf("rollback replay = %#v, %v", replayed, err)
+	}
+	if _, err := state.Rollback(RollbackRequest{RequestID: "undo", Revision: 2}); err == nil {
+		t.Fatal("rollback request_id reused for another revision")
+	}
+	// The undone request applies again rather than replaying.
+	replace("bad", "bad")
+	if got := state.List(ListRequest{}); got.Revision != 4 || string(got.Entries[0].ConfigJSON) != `{"mode":"bad"}` {
+		t.Fatalf("catalog after reapplying = %#v", got)
+	}
+
+	history := state.History()
+	if history.Revision != 4 || len(history.Revisions) != 4 || history.Revisions[0].Revision != 4 || history.Revisions[1].RequestID != "undo" {
+		t.Fatalf("history = %#v", history)
+	}
+	for i := 0; i < HistoryDepth; i++ {
+		replace("bulk-"+string(rune('a'+i)), "bulk")
+	}
+	if _, err := state.Rollback(RollbackRequest{RequestID: "too-old", Revision: 1}); err == nil {
+		t.Fatal("rolled back to a revision past the history depth")
+	}
+	if got := len(state.History().Revisions); got != HistoryDepth {
+		t.Fatalf("retained %d revisions, want %d", got, HistoryDepth)
+	}
+
+	restored := NewState()
+	if _, err := restored.Import(ImportRequest{RequestID: "restore", Snapshot: state.Export()}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored.History(), state.History()) {
+		t.Fatalf("restored history = %#v", restored.History())
+	}
+}
+
+// A cell keys each sync on the revision it saw, so a reload or restart after
+// a rollback applies the template files again rather than replaying.
+func TestStateReloadAfterRollbackAppliesFilesAgain(t *testing.T) {
+	state := NewState()
+	sync := func(id, label string) Catalog {
+		t.Helper()
+		catalog, err := state.Replace(ReplaceRequest{RequestID: id, Entries: []Entry{{Name: "paper", Game: "minecraft", Label: label}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return catalog
+	}
+	sync("sync-at-0", "v1")
+	sync("sync-at-1", "v2")
+	if _, err := state.Rollback(RollbackRequest{RequestID: "undo", Revision: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.List(ListRequest{}); got.Revision != 3 || got.Entries[0].Label != "v1" {
+		t.Fatalf("rolled back catalog = %#v", got)
+	}
+
+	if reloaded := sync("sync-at-3", "v2"); reloaded.Revision != 4 || reloaded.Entries[0].Label != "v2" {
+		t.Fatalf("reload after rollback = %#v", reloaded)
+	}
+}
+
+func TestStateDiffReportsFieldChanges(t *testing.T) {
+	state := NewState()
+	_, err := state.Replace(ReplaceRequest{
+		RequestID: "first",
+		Entries: []Entry{
+			{Name: "paper", Game: "minecraft", CPULimit: 2, ConfigJSON: json.RawMessage(`{"settings":{"pvp":{"type":"boolean","default":true}}}`)},
+			{Name: "legacy", Game: "minecraft"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposed := ReplaceRequest{Entries: []Entry{
+		{Name: "paper", Game: "minecraft", CPULimit: 4, ConfigJSON: json.RawMessage(`{"settings":{"pvp":{"type":"boolean","default":false},"motd":{"type":"text"}}}`)},
+		{Name: "velocity", Game: "proxy"},
+	}}
+	diff, err := state.Diff(DiffRequest{Proposed: &proposed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Diff{
+		From:    1,
+		Added:   []string{"velocity"},
+		Removed: []string{"legacy"},
+		Modified: []TemplateDiff{{Name: "paper", Changes: []FieldChange{
+			{Field: "cpu_limit", Before: json.RawMessage(`2`), After: json.RawMessage(`4`)},
+			{Field: "config_json.settings.motd", After: json.RawMessage(`{"type":"text"}`)},
+			{Field: "config_json.settings.pvp.default", Before: json.RawMessage(`true`), After: json.RawMessage(`false`)},
+		}}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("diff = %+v\nwant %+v", diff, want)
+	}
+	if got := state.List(ListRequest{}); got.Revision != 1 || len(got.Entries) != 2 {
+		t.Fatalf("dry run changed the catalog: %#v", got)
+	}
+
+	proposed.RequestID = "second"
+	if _, err := state.Replace(proposed); err != nil {
+		t.Fatal(err)
+	}
+	between, err := state.Diff(DiffRequest{From: 1, To: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.To = 2
+	if !reflect.DeepEqual(between, want) {
+		t.Fatalf("revision diff = %+v\nwant %+v", between, want)
+	}
+	if _, err := state.Diff(DiffRequest{From: 9}); err == nil {
+		t.Fatal("diffed a revision that was never retained")
+	}
+}
+
+func TestStateUpsertAndDeleteAreIdempotentPerEntry(t *testing.T) {
+	state := NewState()
+	if _, err := state.Replace(ReplaceRequest{
+		RequestID: "load",
+		Entries:   []Entry{{Name: "paper", Game: "minecraft"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	upsert := UpsertRequest{RequestID: "add-velocity", Entry: Entry{Name: "velocity", Game: "proxy"}}
+	added, err := state.Upsert(upsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Revision != 2 || len(added.Entries) != 2 {
+		t.Fatalf("upserted catalog = %#v", added)
+	}
+	if replayed, err := state.Upsert(upsert); err != nil || replayed.Revision != 2 {
+		t.Fatalf("upsert replay = %#v, %v", replayed, err)
+	}
+	upsert.Entry.Label = "Velocity"
+	if _, err := state.Upsert(upsert); err == nil {
+		t.Fatal("upsert request_id reused for a different entry")
+	}
+	if _, err := state.Delete(DeleteRequest{RequestID: "add-velocity", Name: "velocity"}); err == nil {
+		t.Fatal("delete reused an upsert request_id")
+	}
+
+	remove := DeleteRequest{RequestID: "drop-paper", Name: "paper"}
+	removed, err := state.Delete(remove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed.Revision != 3 || len(removed.Entries) != 1 || removed.Entries[0].Name != "velocity" {
+		t.Fatalf("deleted catalog = %#v", removed)
+	}
+	if replayed, err := state.Delete(remove); err != nil || replayed.Revision != 3 {
+		t.Fatalf("delete replay = %#v, %v", replayed, err)
+	}
+	if _, err := state.Delete(DeleteRequest{RequestID: "drop-missing", Name: "paper"}); err == nil {
+		t.Fatal("deleted a template that is not in the catalog")
+	}
+	if got := state.History().Revisions[0].RequestID; got != "drop-paper" {
+		t.Fatalf("newest revision request_id = %q", got)
+	}
+}
+
+func TestStateReplaceReplayDoesNotRevertLaterChanges(t *testing.T) {
+	state := NewState()
+	reload := ReplaceRequest{RequestID: "load", Entries: []Entry{{Name: "paper", Game: "minecraft"}}}
+	if _, err := state.Replace(reload); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.Upsert(UpsertRequest{RequestID: "add-velocity", Entry: Entry{Name: "velocity", Game: "proxy"}}); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := state.Replace(reload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Revision != 2 || len(replayed.Entries) != 2 {
+		t.Fatalf("delayed replay = %#v", replayed)
+	}
+
+	reload.RequestID = "load-at-2"
+	restored, err := state.Replace(reload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Revision != 3 || len(restored.Entries) != 1 || restored.Entries[0].Name != "paper" {
+		t.Fatalf("reload under a new request_id = %#v", restored)
+	}
+}
+
+func TestStateListFiltersAndFacets(t *testing.T) {
+	state := NewState()
+	if _, err := state.Replace(ReplaceRequest{
+		RequestID: "load",
+		Entries: []Entry{
+			{Name: "paper", Game: "minecraft", Label: "Paper Survival"},
+			{Name: "paper-creative", Game: "minecraft", Label: "Paper Creative"},
+			{Name: "bedrock", Game: "minecraft", Engine: "bedrock", Label: "Bedrock Survival"},
+			{Name: "hytale", Game: "hytale", Label: "Hytale Survival"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	names := func(catalog Catalog) []string {
+		var got []string
+		for _, entry := range catalog.Entries {
+			got = append(got, entry.Name)
+		}
+		return got
+	}
+
+	all := state.List(ListRequest{})
+	want := &Facets{
+		Games:   []FacetCount{{Value: "hytale", Count: 1}, {Value: "minecraft", Count: 3}},
+		Engines: []FacetCount{{Value: "bedrock", Count: 1}},
+	}
+	if len(all.Entries) != 4 || !reflect.DeepEqual(all.Facets, want) {
+		t.Fatalf("unfiltered list = %v %+v", names(all), all.Facets)
+	}
+
+	survival := state.List(ListRequest{Game: "minecraft", Label: "SURVIVAL"})
+	if got := names(survival); !reflect.DeepEqual(got, []string{"bedrock", "paper"}) {
+		t.Fatalf("filtered names = %v", got)
+	}
+	// The games facet ignores the game filter but not the label filter.
+	want.Games[1].Count = 2
+	if !reflect.DeepEqual(survival.Facets, want) {
+		t.Fatalf("filtered facets = %+v", survival.Facets)
+	}
+
+	if none := state.List(ListRequest{Label: "lobby"}); none.Entries != nil || none.Facets != nil || none.Revision != 1 {
+		t.Fatalf("empty list = %#v", none)
+	}
+}